@@ -0,0 +1,212 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/backup"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/gameserver"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "List, take and restore snapshots of the mods folders of apps",
+	Long: `Apps with a backup config get a snapshot of their path before updated mods are installed:
+
+  apps:
+    - id: 108600
+      path: /srv/zomboid/mods
+      backup:
+        dir: /srv/backups/mods
+        format: tar.zst  # or tar.gz or hardlink
+        keep: 5
+        max_age: 720h
+
+Restoring a snapshot replaces the content of the path of its app and the installed versions in the state.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+// backupListCmd represents the backup list command
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snapshots of all apps, newest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfig(false)
+
+		var snaps []*backup.Snapshot
+		for _, app := range backupApps() {
+			s, err := backup.List(app)
+			if err != nil {
+				logger.WithError(err).WithField("app_id", app.AppID).Fatal("failed to list snapshots")
+			}
+			snaps = append(snaps, s...)
+		}
+
+		var err error
+		switch backupOut {
+		case "":
+			err = printBackupTable(os.Stdout, snaps)
+		case output.JSONL:
+			for _, s := range snaps {
+				if err = backupOut.Write(os.Stdout, s); err != nil {
+					break
+				}
+			}
+		default:
+			err = backupOut.Write(os.Stdout, snaps)
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to print snapshots")
+		}
+	},
+}
+
+// backupCreateCmd represents the backup create command
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Take a snapshot of the path of all apps with a backup config",
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfig(false)
+
+		st, err := state.Load(cfg.State)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load state")
+		}
+		failed := false
+		for _, app := range backupApps() {
+			snap, err := backup.Take(app, st)
+			if err != nil {
+				logger.WithError(err).WithField("app_id", app.AppID).Error("failed to take snapshot")
+				failed = true
+				continue
+			}
+			if snap == nil {
+				fmt.Printf("%s: nothing to back up\n", app)
+				continue
+			}
+			fmt.Printf("%s: %s %s\n", app, snap.ID, output.Bytes(snap.Bytes))
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// backupRestoreCmd represents the backup restore command
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Restore the path of an app to a snapshot",
+	Long: `Replaces the content of the path of the app of the snapshot with the snapshot and
+records the mods of the snapshot as installed in the state.
+A snapshot of the current content is taken first unless --no-snapshot is set.
+The game server of the app is stopped while the snapshot is restored.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfig(false)
+
+		app, snap, err := backup.Find(cfg.Apps, args[0])
+		if err != nil {
+			logger.WithError(err).Fatal("failed to find snapshot")
+		}
+		st, err := state.Load(cfg.State)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load state")
+		}
+
+		restore := func() error {
+			if !backupNoSnapshot {
+				current, err := backup.Create(app, st)
+				if err != nil {
+					return err
+				}
+				if current != nil {
+					fmt.Printf("took snapshot %s of the current content\n", current.ID)
+				}
+			}
+			return backup.Restore(app, snap, st)
+		}
+		if app.Server != nil {
+			controller, err := gameserver.NewController(app)
+			if err != nil {
+				logger.WithError(err).Fatalf("invalid server of app %s", app)
+			}
+			err = controller.Restart(cmd.Context(), restore)
+		} else {
+			err = restore()
+		}
+		if err != nil {
+			logger.WithError(err).Fatal("failed to restore snapshot")
+		}
+		if err := st.Save(); err != nil {
+			logger.WithError(err).Fatal("failed to save state")
+		}
+		fmt.Printf("restored %s to %s\n", app.Path, snap.ID)
+	},
+}
+
+var (
+	backupOut        output.Output
+	backupApp        string
+	backupNoSnapshot bool
+)
+
+// backupApps returns the apps with a backup config, only the app of --app if set
+func backupApps() []*config.App {
+	if backupApp != "" {
+		app := cfg.Apps.Get(backupApp)
+		if app == nil {
+			logger.Fatalf("app %s is not configured", backupApp)
+		}
+		if app.Backup == nil {
+			logger.Fatalf("app %s has no backup config", app)
+		}
+		return []*config.App{app}
+	}
+	var apps []*config.App
+	for _, app := range cfg.Apps {
+		if app.Backup != nil {
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// printBackupTable prints the snapshots as table
+func printBackupTable(out io.Writer, snaps []*backup.Snapshot) error {
+	if len(snaps) == 0 {
+		_, err := fmt.Fprintln(out, "no snapshots")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tAPP\tCREATED\tFORMAT\tMODS\tSIZE\tPATH")
+	for _, s := range snaps {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			s.ID, s.AppID, formatTime(&s.Created), s.Format, len(s.Mods), output.Bytes(s.Bytes), s.Path)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+
+	backupListCmd.Flags().StringVar(&backupApp, "app", "", "only snapshots of this app")
+	backupCreateCmd.Flags().StringVar(&backupApp, "app", "", "only take a snapshot of this app")
+	backupListCmd.Flags().VarP(&backupOut, "output", "o", "print the snapshots as yaml, json or jsonl instead of a table")
+	backupRestoreCmd.Flags().BoolVar(&backupNoSnapshot, "no-snapshot", false, "do not take a snapshot of the current content first")
+}
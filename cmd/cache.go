@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/Cehir/steam-workshop-downloader/pkg/cache"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Share the workshop cache with other hosts",
+}
+
+// cacheServeCmd represents the cache serve command
+var cacheServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the workshop cache of steamcmd over HTTP",
+	Long: `Serves the workshop content directory of steamcmd, or of the mirror, to other hosts.
+
+  GET /v1/items/{app id}/{workshop id}   manifest of an item with the SHA-256 of its files
+  GET /v1/files/{sha256}                 content of a file of an item
+
+Hosts with steam.source set to cache and steam.cache.url set to the URL of this server fetch their mods
+from it first and only download the mods it does not have, or only has in an older version, with steamcmd.
+Files are addressed by their content, so unchanged files are not transferred again.
+
+If a token is set with --token or the environment variable SWD_CACHE_TOKEN, every request needs the header
+"Authorization: Bearer <token>", clients set it with steam.cache.token.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// only the workshop content directory is needed
+		loadConfig(true)
+		if err := replaceRelativePath(&cfg); err != nil {
+			logger.WithError(err).Fatal("failed to get absolute path")
+		}
+		if info, err := os.Stat(cfg.Steam.ContentDir()); err != nil || !info.IsDir() {
+			logger.WithField("content", cfg.Steam.ContentDir()).Fatal("workshop content directory does not exist")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		s := cache.NewServer(&cfg.Steam, viper.GetString("cache.token"))
+		server := &http.Server{Addr: cacheListen, Handler: s.Handler()}
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdown)
+		}()
+
+		logger.WithField("address", cacheListen).WithField("content", cfg.Steam.ContentDir()).Info("serving workshop cache")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Fatal("failed to serve workshop cache")
+		}
+		logger.Info("stopped serving workshop cache")
+	},
+}
+
+var (
+	cacheListen = "127.0.0.1:8081"
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheServeCmd)
+
+	cacheServeCmd.Flags().StringVar(&cacheListen, "listen", cacheListen, "address to serve the cache on")
+	cacheServeCmd.Flags().String("token", "", "token required in the Authorization header")
+	if err := viper.BindPFlag("cache.token", cacheServeCmd.Flags().Lookup("token")); err != nil {
+		logger.WithError(err).Fatal("failed to bind token flag")
+	}
+}
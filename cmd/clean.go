@@ -0,0 +1,111 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/clean"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove installed and unused mods from the workshop cache of steamcmd",
+	Long: `Removes folders from the workshop content directory of steamcmd for the configured apps:
+
+  installed     mods that are installed at their destination according to the state
+  unconfigured  items that are not in the config
+  stale         unfinished downloads and temp folders of steamcmd
+
+Removed items are also removed from the workshop manifest of steamcmd, they are downloaded again when needed.
+Do not run clean while steamcmd is running. Set steam.clean to remove mods after every download.
+Mirrors of the mirror source are never cleaned.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfig(false)
+		if cfg.Steam.Offline() {
+			logger.Fatal("steam.source is mirror, the mirror is not cleaned")
+		}
+
+		st, err := state.Load(cfg.State)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load state")
+		}
+		entries, err := clean.Plan(&cfg, st)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to find folders to clean")
+		}
+
+		var reclaimed int64
+		var cleanErr error
+		if cleanDryRun {
+			for _, e := range entries {
+				reclaimed += e.Bytes
+			}
+		} else {
+			reclaimed, cleanErr = clean.Remove(&cfg.Steam, entries)
+		}
+
+		switch cleanOut {
+		case "":
+			err = printCleanTable(os.Stdout, entries, reclaimed)
+		case output.JSONL:
+			for _, e := range entries {
+				if err = cleanOut.Write(os.Stdout, e); err != nil {
+					break
+				}
+			}
+		default:
+			err = cleanOut.Write(os.Stdout, entries)
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to print report")
+		}
+		if cleanErr != nil {
+			logger.WithError(cleanErr).Fatal("failed to clean workshop cache")
+		}
+	},
+}
+
+var (
+	cleanOut    output.Output
+	cleanDryRun bool
+)
+
+// printCleanTable prints the removed folders and the reclaimed space
+func printCleanTable(out io.Writer, entries []*clean.Entry, reclaimed int64) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(out, "nothing to clean")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APP\tITEM\tREASON\tSIZE\tPATH")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.AppID, e.WorkshopID, e.Reason, output.Bytes(e.Bytes), e.Path)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if cleanDryRun {
+		_, err := fmt.Fprintf(out, "\nwould reclaim %s, run without --dry-run to remove\n", output.Bytes(reclaimed))
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\nreclaimed %s\n", output.Bytes(reclaimed))
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+
+	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "only show what would be removed")
+	cleanCmd.Flags().VarP(&cleanOut, "output", "o", "print the report as yaml, json or jsonl instead of a table")
+}
@@ -4,12 +4,17 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
 	"github.com/Cehir/steam-workshop-downloader/pkg/path"
 	"github.com/go-playground/validator/v10"
 	logger "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
 )
 
 // configCmd represents the config command
@@ -26,18 +31,31 @@ var configCmd = &cobra.Command{
 	},
 }
 
+// settings of the config files
+var (
+	configSources    config.Sources        // positions of the settings
+	configDeprecated []*config.Deprecation // deprecated settings
+	configErr        error                 // error reading the config file, nil if it was read or there is none
+)
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 }
 
 func loadConfig(skipValidationErr bool) {
+	if len(configDeprecated) > 0 && !skipValidationErr {
+		logDeprecated()
+		os.Exit(1)
+	}
 	if err := viper.Unmarshal(&cfg); err != nil {
 		logger.WithError(err).Fatal("failed to unmarshal config")
 	}
 
 	//replace relative path with absolute path before validation
 	if skipValidationErr == false {
-		replaceRelativePath()
+		if err := replaceRelativePath(&cfg); err != nil {
+			logger.WithError(err).Fatal("failed to get absolute path")
+		}
 	}
 
 	// validate config
@@ -45,14 +63,7 @@ func loadConfig(skipValidationErr bool) {
 		if skipValidationErr {
 			return
 		}
-		switch err.(type) {
-		case validator.ValidationErrors:
-			for _, e := range err.(validator.ValidationErrors) {
-				logger.WithField("field", e.Namespace()).WithField("rule", e.Translate(trans)).Error("Validation failed")
-			}
-		default:
-			logger.WithError(err).Error("Config validation failed")
-		}
+		logValidationErr(err)
 		os.Exit(1)
 	}
 
@@ -63,14 +74,169 @@ func loadConfig(skipValidationErr bool) {
 	}).Debug("loading config complete")
 }
 
-// replaceRelativePath replaces the relative path with the absolute path
-func replaceRelativePath() {
+// reloadConfig reads the config like loadConfig but returns errors instead of exiting
+func reloadConfig() (*config.Config, error) {
+	// the config file was read again without its includes and the profile
+	if err := applyConfigFile(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if len(configDeprecated) > 0 {
+		logDeprecated()
+		return nil, fmt.Errorf("config has deprecated settings")
+	}
+	var c config.Config
+	if err := viper.Unmarshal(&c); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	if err := replaceRelativePath(&c); err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		logValidationErr(err)
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	return &c, nil
+}
+
+// readSettings returns the settings of the config file and the files it includes
+// without defaults and environment variables
+func readSettings() (map[string]any, error) {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return nil, fmt.Errorf("no config file")
+	}
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml", ".json", "":
+		files, err := config.Read(file)
+		if err != nil {
+			return nil, err
+		}
+		configSources = files.Sources
+		configDeprecated = files.Deprecated
+		for _, c := range files.Changes {
+			logger.WithField("change", c.String()).Warn("config file has an old version and was migrated in memory, run config migrate")
+		}
+		return files.Settings, nil
+	}
+
+	// other formats of viper do not support includes
+	raw := viper.New()
+	raw.SetConfigFile(file)
+	if err := raw.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return raw.AllSettings(), nil
+}
+
+// readProfiles returns the settings of the config file like readSettings and its profiles,
+// including those of the profiles folder next to it
+func readProfiles() (map[string]any, map[string]map[string]any, error) {
+	settings, err := readSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	profiles, err := config.Profiles(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	file := viper.ConfigFileUsed()
+	dir := filepath.Join(filepath.Dir(file), config.ProfilesKey)
+	files, err := config.ReadProfiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, p := range files {
+		if _, ok := profiles[name]; ok {
+			return nil, nil, fmt.Errorf("profile %s is defined in %s and in %s", name, file, dir)
+		}
+		profiles[name] = p
+	}
+	return settings, profiles, nil
+}
+
+// applyConfigFile merges the included files and the selected profile over the config file.
+// Environment variables still take precedence over them.
+func applyConfigFile() error {
+	settings, profiles, err := readProfiles()
+	if err != nil {
+		return err
+	}
+	if name := viper.GetString("profile"); name != "" {
+		if settings, err = config.ApplyProfile(settings, profiles, name); err != nil {
+			return err
+		}
+		logger.WithField("profile", name).Debug("using profile")
+	}
+
+	// replace the settings of the config file, merging keeps values that changed their type
+	b, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	viper.SetConfigType("yaml")
+	return viper.ReadConfig(bytes.NewReader(b))
+}
+
+// logDeprecated logs every deprecated setting of the config files
+func logDeprecated() {
+	for _, d := range configDeprecated {
+		logger.WithField("field", d.Path).WithField("rule", d.Error()).WithField("source", d.Position.String()).Error("Validation failed")
+	}
+}
+
+// logValidationErr logs every failed validation of err
+func logValidationErr(err error) {
+	switch err.(type) {
+	case validator.ValidationErrors:
+		for _, e := range err.(validator.ValidationErrors) {
+			l := logger.WithField("field", e.Namespace()).WithField("rule", e.Translate(trans))
+			if pos, ok := configSources.Lookup(e.Namespace()); ok {
+				l = l.WithField("source", pos.String())
+			}
+			l.Error("Validation failed")
+		}
+	default:
+		logger.WithError(err).Error("Config validation failed")
+	}
+}
+
+// replaceRelativePath replaces the relative paths of c with absolute paths
+func replaceRelativePath(c *config.Config) error {
 	p := path.NewPath()
-	for i, app := range cfg.Apps {
+	for i, app := range c.Apps {
 		absolute, err := p.Absolute(app.Path)
 		if err != nil {
-			logger.WithError(err).Fatal("failed to get absolute path")
+			return err
+		}
+		c.Apps[i].Path = absolute
+		if app.Backup != nil && app.Backup.Dir != "" {
+			absolute, err := p.Absolute(app.Backup.Dir)
+			if err != nil {
+				return err
+			}
+			app.Backup.Dir = absolute
+		}
+	}
+	if c.Steam.Mirror != "" {
+		absolute, err := p.Absolute(c.Steam.Mirror)
+		if err != nil {
+			return err
+		}
+		c.Steam.Mirror = absolute
+	}
+	if c.Steam.Content != "" {
+		absolute, err := p.Absolute(c.Steam.Content)
+		if err != nil {
+			return err
+		}
+		c.Steam.Content = absolute
+	}
+	if c.State != "" {
+		absolute, err := p.Absolute(c.State)
+		if err != nil {
+			return err
 		}
-		cfg.Apps[i].Path = absolute
+		c.State = absolute
 	}
+	return nil
 }
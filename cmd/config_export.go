@@ -28,5 +28,5 @@ func init() {
 	configCmd.AddCommand(configShow)
 
 	// Cobra supports local flags
-	configShow.Flags().VarP(&out, "out", "o", "output format (yaml or json)")
+	configShow.Flags().VarP(&out, "out", "o", "output format (yaml, json or jsonl)")
 }
@@ -0,0 +1,67 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"path/filepath"
+)
+
+// configMigrate represents the config migrate command
+var configMigrate = &cobra.Command{
+	Use:   "migrate",
+	Short: "rewrite the config file in the latest version",
+	Long: fmt.Sprintf(`Migrates the config file and the files it includes to version %d and writes the changed files.
+Older versions are also migrated in memory when the config is read, this command makes it permanent.
+Comments of YAML files are kept, profiles files are not migrated.`, config.Version),
+	Run: func(cmd *cobra.Command, args []string) {
+		file := viper.ConfigFileUsed()
+		if file == "" {
+			logger.Fatal("no config file, set --config")
+		}
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".json", "":
+		default:
+			logger.WithField("file", file).Fatal("only YAML and JSON config files can be migrated")
+		}
+
+		var changes []*config.Change
+		var err error
+		if configMigrateDryRun {
+			var files *config.Files
+			if files, err = config.Read(file); err == nil {
+				changes = files.Changes
+			}
+		} else {
+			changes, err = config.Migrate(file)
+		}
+		if err != nil {
+			logger.WithError(err).Fatal("failed to migrate config")
+		}
+
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		switch {
+		case configMigrateDryRun:
+			fmt.Printf("%d changes to migrate %s to version %d\n", len(changes), file, config.Version)
+		default:
+			fmt.Printf("migrated %s to version %d\n", file, config.Version)
+		}
+	},
+}
+
+var (
+	configMigrateDryRun bool
+)
+
+func init() {
+	configCmd.AddCommand(configMigrate)
+
+	configMigrate.Flags().BoolVar(&configMigrateDryRun, "dry-run", false, "print the changes without writing them")
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"text/tabwriter"
+)
+
+// configProfiles represents the config profiles command
+var configProfiles = &cobra.Command{
+	Use:   "profiles",
+	Short: "list the profiles of the config file",
+	Long: `Lists the profiles of the config file and of the profiles folder next to it.
+Select a profile with --profile or SWD_PROFILE, config show prints the config with the profile applied.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, profiles, err := readProfiles()
+		if err != nil {
+			logger.WithError(err).Fatal("failed to read profiles")
+		}
+		if len(profiles) == 0 {
+			fmt.Println("no profiles")
+			return
+		}
+
+		current := viper.GetString("profile")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "PROFILE\tEXTENDS\tACTIVE")
+		for _, name := range config.ProfileNames(profiles) {
+			extends, _ := profiles[name][config.ExtendsKey].(string)
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", name, extends, yesNo(name == current))
+		}
+		_ = w.Flush()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configProfiles)
+}
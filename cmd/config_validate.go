@@ -0,0 +1,72 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/workshop"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"strconv"
+)
+
+// configValidate represents the config validate command
+var configValidate = &cobra.Command{
+	Use:   "validate",
+	Short: "validate the config and report every problem",
+	Long: `Validates the config like every other command and reports all problems at once:
+ids that are not numeric, duplicate apps, mods configured twice for an app, apps sharing a path
+and paths that do not exist or are not writable.
+
+With --online the mods are also looked up in the workshop, mods that do not exist
+or belong to another app than the one they are configured for are reported.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(configDeprecated) > 0 {
+			logDeprecated()
+			os.Exit(1)
+		}
+		if err := viper.Unmarshal(&cfg); err != nil {
+			logger.WithError(err).Fatal("failed to unmarshal config")
+		}
+		if err := replaceRelativePath(&cfg); err != nil {
+			logger.WithError(err).Fatal("failed to get absolute path")
+		}
+
+		ctx := cmd.Context()
+		if configValidateOnline {
+			details, err := workshop.NewClient().Details(ctx, cfg.Apps.WorkshopIDs())
+			if err != nil {
+				logger.WithError(err).Fatal("failed to get workshop details")
+			}
+			items := make(map[string]config.WorkshopItem, len(details))
+			for id, d := range details {
+				item := config.WorkshopItem{Exists: d.Exists()}
+				if d.AppID != 0 {
+					item.AppID = strconv.FormatInt(int64(d.AppID), 10)
+				}
+				items[id] = item
+			}
+			ctx = config.WithWorkshop(ctx, items)
+		}
+
+		if err := cfg.ValidateContext(config.WithWritable(ctx)); err != nil {
+			logValidationErr(err)
+			os.Exit(1)
+		}
+		fmt.Println("config is valid")
+	},
+}
+
+var (
+	configValidateOnline bool
+)
+
+func init() {
+	configCmd.AddCommand(configValidate)
+
+	configValidate.Flags().BoolVar(&configValidateOnline, "online", false, "check that the mods exist in the workshop and belong to their app")
+}
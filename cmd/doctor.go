@@ -0,0 +1,180 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/doctor"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the config and the environment",
+	Long: `Checks everything a download needs and prints how to fix what is wrong:
+
+  - the config file loads and validates
+  - steamcmd exists, is executable and starts until its prompt
+  - the workshop cache of steamcmd is writable
+  - the free space on the volume of every app path
+
+It also prints the config file and the environment variables overriding its settings.
+Exits with 1 if a check failed.`,
+	// errors of the config file are reported as checks
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		checks := configChecks(cmd.Context())
+		// the settings of a config file that failed to load are unknown
+		if configErr == nil && !cfg.Steam.Offline() {
+			checks = append(checks, doctor.SteamCMD(cmd.Context(), cfg.Steam.Cmd, doctorTimeout)...)
+			checks = append(checks, doctor.Writable("workshop cache", cfg.Steam.ContentDir(),
+				"make the directory writable for this user or set steam.content to another directory"))
+		}
+		for _, app := range cfg.Apps {
+			if app == nil || app.Path == "" {
+				continue
+			}
+			name := app.Name
+			if name == "" {
+				name = app.AppID
+			}
+			checks = append(checks, doctor.DiskSpace("disk space "+name, app.Path))
+		}
+
+		if err := printDoctor(os.Stdout, checks); err != nil {
+			cobra.CheckErr(err)
+		}
+		if doctor.Failed(checks) {
+			os.Exit(1)
+		}
+	},
+}
+
+var (
+	doctorTimeout = 2 * time.Minute
+)
+
+// configChecks loads the config into cfg and checks that it validates
+func configChecks(ctx context.Context) []*doctor.Check {
+	file := viper.ConfigFileUsed()
+	if file == "" || configErr != nil {
+		// the defaults still tell where steamcmd is expected
+		cfg = config.Config{}
+		_ = viper.Unmarshal(&cfg)
+		if cmd, err := path.NewPath().Absolute(cfg.Steam.Cmd); err == nil {
+			cfg.Steam.Cmd = cmd
+		}
+		if configErr != nil {
+			return []*doctor.Check{{Name: "config file", Status: doctor.Fail, Detail: configErr.Error(),
+				Fix: "fix the syntax, includes and version of " + file}}
+		}
+		return []*doctor.Check{{Name: "config file", Status: doctor.Fail, Detail: "no config file found",
+			Fix: "create $HOME/.steam-workshop-downloader.yaml or set --config"}}
+	}
+	detail := file
+	if abs, err := filepath.Abs(file); err == nil {
+		detail = abs
+	}
+	if profile := viper.GetString("profile"); profile != "" {
+		detail += ", profile " + profile
+	}
+	checks := []*doctor.Check{{Name: "config file", Status: doctor.OK, Detail: detail}}
+
+	for _, d := range configDeprecated {
+		checks = append(checks, &doctor.Check{Name: "config", Status: doctor.Fail,
+			Detail: fmt.Sprintf("%s (%s)", d.Error(), d.Position), Fix: "run config migrate"})
+	}
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return append(checks, &doctor.Check{Name: "config", Status: doctor.Fail, Detail: err.Error(),
+			Fix: "fix the types of the settings in " + file})
+	}
+	if err := replaceRelativePath(&cfg); err != nil {
+		return append(checks, &doctor.Check{Name: "config", Status: doctor.Fail, Detail: err.Error(),
+			Fix: "use absolute paths in " + file})
+	}
+
+	switch err := cfg.ValidateContext(config.WithWritable(ctx)).(type) {
+	case nil:
+		checks = append(checks, &doctor.Check{Name: "config", Status: doctor.OK, Detail: "valid"})
+	case validator.ValidationErrors:
+		for _, e := range err {
+			where := file
+			if pos, ok := configSources.Lookup(e.Namespace()); ok {
+				where = pos.String()
+			}
+			checks = append(checks, &doctor.Check{Name: "config", Status: doctor.Fail,
+				Detail: fmt.Sprintf("%s: %s", e.Namespace(), e.Translate(trans)),
+				Fix:    fmt.Sprintf("fix %s in %s", strings.TrimPrefix(e.Namespace(), "Config."), where)})
+		}
+	default:
+		checks = append(checks, &doctor.Check{Name: "config", Status: doctor.Fail, Detail: err.Error(),
+			Fix: "fix the config in " + file})
+	}
+	return checks
+}
+
+// envOverrides returns the environment variables that override settings, their values are not shown
+func envOverrides() []string {
+	var vars []string
+	for _, key := range viper.AllKeys() {
+		name := "SWD_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if _, ok := os.LookupEnv(name); ok {
+			vars = append(vars, fmt.Sprintf("%s overrides %s", name, key))
+		}
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// printDoctor prints the checks as table followed by the environment overrides and the fixes
+func printDoctor(out io.Writer, checks []*doctor.Check) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, c := range checks {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, c.Status, c.Detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if vars := envOverrides(); len(vars) > 0 {
+		_, _ = fmt.Fprintln(out, "\nEnvironment:")
+		for _, v := range vars {
+			_, _ = fmt.Fprintf(out, "  %s\n", v)
+		}
+	}
+
+	var fixes []string
+	for _, c := range checks {
+		if c.Status != doctor.OK && c.Fix != "" {
+			fixes = append(fixes, fmt.Sprintf("%s: %s", c.Name, c.Fix))
+		}
+	}
+	if len(fixes) > 0 {
+		_, _ = fmt.Fprintln(out, "\nFixes:")
+		for i, f := range fixes {
+			_, _ = fmt.Fprintf(out, "  %d. %s\n", i+1, f)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().DurationVar(&doctorTimeout, "timeout", doctorTimeout, "how long to wait for the prompt of steamcmd")
+}
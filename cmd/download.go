@@ -5,10 +5,14 @@ package cmd
 
 import (
 	"github.com/Cehir/steam-workshop-downloader/pkg/config"
-	"github.com/Cehir/steam-workshop-downloader/pkg/steamcmd"
+	"github.com/Cehir/steam-workshop-downloader/pkg/metrics"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/progress"
+	"github.com/Cehir/steam-workshop-downloader/pkg/runner"
 	"github.com/Cehir/steam-workshop-downloader/pkg/translations/en"
 	logger "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"os"
 )
 
 // downloadCmd represents the download command
@@ -17,8 +21,43 @@ var downloadCmd = &cobra.Command{
 	Short: "Download the configured mods",
 	Run: func(cmd *cobra.Command, args []string) {
 		loadConfig(false)
-		c := steamcmd.NewSteamCmd(&cfg)
-		err := c.Download()
+		c := runner.NewRunner()
+
+		// progress is written to stderr and only shown on request if the output is used
+		progressOut := os.Stdout
+		if downloadOut != "" {
+			progressOut = os.Stderr
+			if !cmd.Flags().Changed("progress") {
+				progressMode = progress.None
+			}
+		}
+		if d := progress.New(progressOut, progressMode); d != nil {
+			c.OnEvent(d.Handle)
+		}
+		if h := downloadOut.Events(os.Stdout); h != nil {
+			c.OnEvent(h)
+		}
+
+		var m *metrics.Metrics
+		if metricsFile != "" {
+			m = newMetrics(c)
+			// the counters of the file keep counting across runs
+			if err := m.ReadFile(metricsFile); err != nil {
+				logger.WithError(err).Warn("failed to read metrics file, its counters start again")
+			}
+		}
+
+		summary, err := c.Run(cmd.Context(), &cfg, runner.Options{})
+		if m != nil {
+			if err := m.WriteFile(metricsFile); err != nil {
+				logger.WithError(err).Error("failed to write metrics")
+			}
+		}
+		if downloadOut == output.YAML || downloadOut == output.JSON {
+			if err := downloadOut.Write(os.Stdout, summary); err != nil {
+				logger.WithError(err).Error("failed to print summary")
+			}
+		}
 		if err != nil {
 			logger.WithError(err).Fatal("failed to download mods")
 		}
@@ -26,9 +65,19 @@ var downloadCmd = &cobra.Command{
 	},
 }
 
+var (
+	progressMode = progress.Auto
+	downloadOut  output.Output
+	metricsFile  string
+)
+
 func init() {
 	rootCmd.AddCommand(downloadCmd)
 
+	downloadCmd.Flags().VarP(&downloadOut, "output", "o", "print a summary (yaml or json) or stream events as JSON lines (jsonl)")
+	downloadCmd.Flags().StringVar(&metricsFile, "metrics-file", "", "write metrics to this file for the textfile collector of the node exporter")
+	downloadCmd.Flags().Var(&progressMode, "progress", "progress output (auto, bar, plain or none)")
+
 	err := en.RegisterDefaultTranslations(config.Validator, trans)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to register translations")
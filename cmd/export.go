@@ -0,0 +1,109 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/archive"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/pack"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export the mods from the workshop cache to a mod pack",
+	Long: `Bundles the mods of all apps or of one app from the workshop cache of steamcmd into a zip, tar.gz or tar.zst archive
+with a manifest of the mods, their versions and the SHA-256 of their files.
+The format is taken from the extension of the file unless --format is set.
+Install the pack with import-pack, e.g. on a server without network.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfig(false)
+
+		file := args[0]
+		format := exportFormat
+		if format == "" {
+			f, err := archive.FormatOf(file)
+			if err != nil {
+				logger.WithError(err).Fatal("set --format to zip, tar.gz or tar.zst")
+			}
+			format = f
+		}
+
+		c := &cfg
+		if exportApp != "" {
+			if c.Apps.Get(exportApp) == nil {
+				logger.Fatalf("app %s is not configured", exportApp)
+			}
+			c = cfg.Filter(func(app *config.App, mod *config.Mod) bool { return app.AppID == exportApp })
+		}
+
+		if exportSkipMissing {
+			c = c.Filter(func(app *config.App, mod *config.Mod) bool {
+				if _, err := os.Stat(filepath.Join(c.Steam.ModDir(app.AppID, mod.WorkshopID), "mods")); err != nil {
+					logger.WithField("workshop_id", mod.WorkshopID).Warn("mod is not in the workshop cache, skipping")
+					return false
+				}
+				return true
+			})
+		}
+
+		st, err := state.Load(c.State)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load state")
+		}
+
+		// the pack is written to a temporary file and renamed when complete
+		f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+		if err != nil {
+			logger.WithError(err).Fatal("failed to create mod pack")
+		}
+
+		m, err := pack.Export(c, st, f, format)
+		if err == nil {
+			err = f.Chmod(0o644)
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), file)
+		}
+		if err != nil {
+			_ = os.Remove(f.Name())
+			logger.WithError(err).Fatal("failed to export mods")
+		}
+
+		mods := 0
+		var size int64
+		for _, a := range m.Apps {
+			mods += len(a.Mods)
+			for _, mod := range a.Mods {
+				size += mod.Bytes
+			}
+		}
+		fmt.Printf("exported %d mods of %d apps (%s) to %s\n", mods, len(m.Apps), output.Bytes(size), file)
+	},
+}
+
+var (
+	exportFormat      archive.Format
+	exportApp         string
+	exportSkipMissing bool
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().Var(&exportFormat, "format", "format of the pack, zip, tar.gz or tar.zst")
+	exportCmd.Flags().StringVar(&exportApp, "app", "", "export only the mods of this app")
+	exportCmd.Flags().BoolVar(&exportSkipMissing, "skip-missing", false, "skip mods that are not in the workshop cache instead of failing")
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/backup"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/gameserver"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/pack"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// importPackCmd represents the import-pack command
+var importPackCmd = &cobra.Command{
+	Use:   "import-pack <file>",
+	Short: "Install the mods of a mod pack without steamcmd",
+	Long: `Installs the mods of a pack created with export to the paths of the configured apps and records them in the state.
+The files of all mods are checked against the manifest of the pack before anything is installed.
+Apps of the pack that are not configured are skipped, steamcmd and network are not needed.
+Apps with a backup config get a snapshot first, game servers are stopped while their mods are installed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// steamcmd is not needed, only the apps have to be valid
+		loadConfig(true)
+		if err := replaceRelativePath(&cfg); err != nil {
+			logger.WithError(err).Fatal("failed to get absolute path")
+		}
+		for _, app := range cfg.Apps {
+			if err := config.Validator.Struct(app); err != nil {
+				logValidationErr(err)
+				os.Exit(1)
+			}
+		}
+
+		p, err := pack.Open(args[0])
+		if err != nil {
+			logger.WithError(err).Fatal("failed to open mod pack")
+		}
+		defer func() {
+			_ = p.Close()
+		}()
+
+		st, err := state.Load(cfg.State)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load state")
+		}
+
+		var results []*pack.Result
+		failed := false
+		for _, a := range p.Manifest.Apps {
+			app := cfg.Apps.Get(a.AppID)
+			if importPackApp != "" && a.AppID != importPackApp {
+				continue
+			}
+			if app == nil {
+				logger.WithField("app_id", a.AppID).Warn("app of the mod pack is not configured, skipping")
+				continue
+			}
+
+			install := func() error {
+				if app.Backup != nil {
+					if _, err := backup.Take(app, st); err != nil {
+						return fmt.Errorf("failed to back up app %s: %w", app, err)
+					}
+				}
+				r, err := p.Install(app, st)
+				results = append(results, r...)
+				return err
+			}
+			if app.Server != nil {
+				var controller *gameserver.Controller
+				if controller, err = gameserver.NewController(app); err == nil {
+					err = controller.Restart(cmd.Context(), install)
+				}
+			} else {
+				err = install()
+			}
+			if err != nil {
+				logger.WithError(err).WithField("app_id", app.AppID).Error("failed to install mod pack")
+				failed = true
+			}
+		}
+		if err := st.Save(); err != nil {
+			logger.WithError(err).Error("failed to save state")
+		}
+
+		switch importPackOut {
+		case "":
+			err = printImportTable(os.Stdout, results)
+		case output.JSONL:
+			for _, r := range results {
+				if err = importPackOut.Write(os.Stdout, r); err != nil {
+					break
+				}
+			}
+		default:
+			err = importPackOut.Write(os.Stdout, results)
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to print report")
+		}
+		if failed || len(results) == 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var (
+	importPackOut output.Output
+	importPackApp string
+)
+
+// printImportTable prints the installed mods of a pack
+func printImportTable(out io.Writer, results []*pack.Result) error {
+	if len(results) == 0 {
+		_, err := fmt.Fprintln(out, "no mods installed")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APP\tMOD\tNAME\tSTATUS\tPATH")
+	for _, r := range results {
+		status := "installed"
+		if !r.Installed {
+			status = "failed: " + r.Error
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.AppID, r.WorkshopID, r.Name, status, r.Path)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(importPackCmd)
+
+	importPackCmd.Flags().VarP(&importPackOut, "output", "o", "print the report as yaml, json or jsonl instead of a table")
+	importPackCmd.Flags().StringVar(&importPackApp, "app", "", "install only the mods of this app")
+}
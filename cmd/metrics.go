@@ -0,0 +1,23 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/Cehir/steam-workshop-downloader/pkg/metrics"
+	"github.com/Cehir/steam-workshop-downloader/pkg/runner"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	logger "github.com/sirupsen/logrus"
+)
+
+// newMetrics returns metrics recording all runs of r, seeded with the installed mods of the state
+func newMetrics(r *runner.Runner) *metrics.Metrics {
+	m := metrics.NewMetrics()
+	if st, err := state.Load(cfg.State); err != nil {
+		logger.WithError(err).Warn("failed to load state for metrics")
+	} else {
+		m.Load(st)
+	}
+	r.OnEvent(m.Handle)
+	return m
+}
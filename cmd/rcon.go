@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/rcon"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+// rconCmd represents the rcon command
+var rconCmd = &cobra.Command{
+	Use:   "rcon <command>",
+	Short: "Execute a command on a game server via RCON",
+	Long: `Executes a command on a game server via RCON and prints the response.
+
+The RCON endpoint of the server of the app selected with --app is used,
+the app can be omitted if only one app has an RCON endpoint.
+--address and --password or the environment variable SWD_RCON_PASSWORD override the endpoint.`,
+	Example: `  steam-workshop-downloader rcon --app 108600 players
+  steam-workshop-downloader rcon --address 127.0.0.1:27015 servermsg "restart in 5 minutes"`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		endpoint := &config.RCON{Address: rconAddress}
+		if endpoint.Address == "" {
+			loadConfig(false)
+			var err error
+			if endpoint, err = rconEndpoint(cfg.Apps, rconApp); err != nil {
+				logger.WithError(err).Fatal("failed to find rcon endpoint")
+			}
+		}
+		password := endpoint.Password
+		if p := viper.GetString("rcon.password"); p != "" {
+			password = p
+		}
+
+		client, err := rcon.Dial(endpoint.Address, password, rconTimeout)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to connect to rcon")
+		}
+		defer func() {
+			_ = client.Close()
+		}()
+
+		response, err := client.Exec(strings.Join(args, " "))
+		if err != nil {
+			logger.WithError(err).Fatal("failed to execute rcon command")
+		}
+		if response != "" {
+			fmt.Println(strings.TrimRight(response, "\n"))
+		}
+	},
+}
+
+var (
+	rconApp     string
+	rconAddress string
+	rconTimeout = 10 * time.Second
+)
+
+// rconEndpoint returns the RCON endpoint of the server of the app, appID may be empty if only one app has one
+func rconEndpoint(apps config.Apps, appID string) (*config.RCON, error) {
+	var found []*config.App
+	for _, app := range apps {
+		if app.Server == nil || app.Server.RCON == nil {
+			continue
+		}
+		if appID == "" || app.AppID == appID {
+			found = append(found, app)
+		}
+	}
+
+	switch {
+	case len(found) == 1:
+		return found[0].Server.RCON, nil
+	case appID != "":
+		return nil, fmt.Errorf("app %s has no server with rcon", appID)
+	case len(found) == 0:
+		return nil, fmt.Errorf("no app has a server with rcon")
+	default:
+		return nil, fmt.Errorf("%d apps have a server with rcon, select one with --app", len(found))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(rconCmd)
+
+	rconCmd.Flags().StringVar(&rconApp, "app", "", "id of the app whose server rcon endpoint is used")
+	rconCmd.Flags().StringVar(&rconAddress, "address", "", "address of the rcon endpoint e.g. 127.0.0.1:27015")
+	rconCmd.Flags().DurationVar(&rconTimeout, "timeout", rconTimeout, "timeout of the connection and the command")
+	rconCmd.Flags().String("password", "", "rcon password")
+	if err := viper.BindPFlag("rcon.password", rconCmd.Flags().Lookup("password")); err != nil {
+		logger.WithError(err).Fatal("failed to bind password flag")
+	}
+}
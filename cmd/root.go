@@ -22,6 +22,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"github.com/Cehir/steam-workshop-downloader/pkg/config"
 	english "github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
@@ -54,6 +55,12 @@ var rootCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return cmd.Help()
 	},
+	// commands need a config file that could be read, doctor reports the error instead
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if configErr != nil {
+			logger.WithError(configErr).Fatal("failed to read config file")
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -72,6 +79,10 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.steam-workshop-downloader.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "info output")
 	rootCmd.PersistentFlags().BoolVar(&veryVerbose, "vv", false, "debug output")
+	rootCmd.PersistentFlags().String("profile", "", "profile of the config file to use, also set with SWD_PROFILE")
+	if err := viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile")); err != nil {
+		logger.WithError(err).Fatal("failed to bind profile flag")
+	}
 }
 
 func initLogger() {
@@ -97,6 +108,7 @@ func initConfig() {
 	viper.SetDefault("steam.cmd", config.DefaultSteamCMDPath())
 	viper.SetDefault("steam.login.username", "anonymous")
 	viper.SetDefault("steam.login.password", "")
+	viper.SetDefault("state", "$HOME/.steam-workshop-downloader.state.json")
 
 	if cfgFile != "" {
 		// Use config file from the flag.
@@ -120,7 +132,18 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		logger.Debug("Using config file:", viper.ConfigFileUsed())
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			configErr = err
+			return
+		}
+		if viper.GetString("profile") != "" {
+			logger.WithError(err).Fatal("profiles need a config file")
+		}
+		return
 	}
+	logger.Debug("Using config file:", viper.ConfigFileUsed())
+
+	configErr = applyConfigFile()
 }
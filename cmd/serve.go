@@ -0,0 +1,79 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/Cehir/steam-workshop-downloader/pkg/api"
+	"github.com/Cehir/steam-workshop-downloader/pkg/runner"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a REST API to trigger and inspect downloads",
+	Long: `Serves a REST API to list the configured apps, trigger downloads and inspect their results.
+
+  GET  /apps                 list all configured apps with their mods
+  GET  /apps/{id}            get a single app
+  POST /apps/{id}/download   download the mods of a single app
+  POST /download             download the mods of all apps
+  GET  /events               stream the events of all runs as JSON lines
+  GET  /report               get the summary of the last run
+  GET  /status               get the status of the runner
+  GET  /metrics              get metrics in the Prometheus text format
+
+Every request needs the header "Authorization: Bearer <token>".
+The token is set with --token or the environment variable SWD_SERVE_TOKEN.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		token := viper.GetString("serve.token")
+		if token == "" {
+			logger.Fatal("a token is required, set --token or SWD_SERVE_TOKEN")
+		}
+		loadConfig(false)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		r := runner.NewRunner()
+		s := api.NewServer(ctx, &cfg, r, token)
+		s.Handle("/metrics", newMetrics(r).Handler())
+		server := &http.Server{Addr: serveListen, Handler: s.Handler()}
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdown)
+		}()
+
+		logger.WithField("address", serveListen).Info("serving api")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Fatal("failed to serve api")
+		}
+		logger.Info("stopped serving api")
+	},
+}
+
+var (
+	serveListen = "127.0.0.1:8080"
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveListen, "listen", serveListen, "address to serve the api on")
+	serveCmd.Flags().String("token", "", "token required in the Authorization header")
+	if err := viper.BindPFlag("serve.token", serveCmd.Flags().Lookup("token")); err != nil {
+		logger.WithError(err).Fatal("failed to bind token flag")
+	}
+}
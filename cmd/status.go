@@ -0,0 +1,115 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"github.com/Cehir/steam-workshop-downloader/pkg/status"
+	"github.com/Cehir/steam-workshop-downloader/pkg/workshop"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the installed mods and whether they are up to date",
+	Long: `Shows for every configured mod whether it is installed at the destination of its app,
+the workshop time of the installed version from the state or the workshop manifest of steamcmd,
+the workshop time of the latest version and whether the mod is out of date.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfig(false)
+
+		c := &cfg
+		if statusApp != "" {
+			if c.Apps.Get(statusApp) == nil {
+				logger.Fatalf("app %s is not configured", statusApp)
+			}
+			c = cfg.Filter(func(app *config.App, mod *config.Mod) bool { return app.AppID == statusApp })
+		}
+
+		st, err := state.Load(c.State)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load state")
+		}
+
+		var details map[string]*workshop.Details
+		if !statusOffline && !c.Steam.Offline() {
+			details, err = workshop.NewClient().Details(cmd.Context(), c.Apps.WorkshopIDs())
+			if err != nil {
+				logger.WithError(err).Warn("failed to get workshop details, latest versions are unknown")
+			}
+		}
+
+		mods := status.Collect(c, st, details)
+		switch statusOut {
+		case "":
+			err = printStatusTable(os.Stdout, mods)
+		case output.JSONL:
+			for _, m := range mods {
+				if err = statusOut.Write(os.Stdout, m); err != nil {
+					break
+				}
+			}
+		default:
+			err = statusOut.Write(os.Stdout, mods)
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to print status")
+		}
+	},
+}
+
+var (
+	statusOut     output.Output
+	statusApp     string
+	statusOffline bool
+)
+
+// printStatusTable prints the status of the mods as table
+func printStatusTable(out io.Writer, mods []*status.Mod) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APP\tMOD\tNAME\tINSTALLED\tLOCAL\tSOURCE\tWORKSHOP\tOUT OF DATE")
+	for _, m := range mods {
+		name := m.Title
+		if name == "" {
+			name = m.Name
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			m.AppID, m.WorkshopID, name, yesNo(m.Installed), formatTime(m.LocalUpdated), m.LocalSource,
+			formatTime(m.RemoteUpdated), yesNo(m.OutOfDate))
+	}
+	return w.Flush()
+}
+
+// formatTime formats a time for tables, nil is unknown
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// yesNo formats a bool for tables
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().VarP(&statusOut, "output", "o", "print the status as yaml, json or jsonl instead of a table")
+	statusCmd.Flags().StringVar(&statusApp, "app", "", "show only the mods of this app")
+	statusCmd.Flags().BoolVar(&statusOffline, "offline", false, "do not ask the workshop for the latest versions")
+}
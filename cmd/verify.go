@@ -0,0 +1,196 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/runner"
+	"github.com/Cehir/steam-workshop-downloader/pkg/verify"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// repair modes of the verify command
+const (
+	repairCopy     = "copy"     // copy the mod from the workshop cache again
+	repairValidate = "validate" // download the mod again with steamcmd validate and copy it
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the installed mods against the workshop cache",
+	Long: `Compares the files of every mod in the destination of its app with the workshop content directory of steamcmd
+by size and SHA-256 and reports missing, modified and extra files.
+
+Extra files are files in the folders of a mod that are not in the workshop cache.
+--repair copy copies the mods with differences from the workshop cache again,
+--repair validate downloads them again with steamcmd validate and copies them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if verifyRepair != "" && verifyRepair != repairCopy && verifyRepair != repairValidate {
+			logger.Fatalf("invalid repair mode %q, must be %q or %q", verifyRepair, repairCopy, repairValidate)
+		}
+		loadConfig(false)
+
+		apps := cfg.Apps
+		if verifyApp != "" {
+			app := cfg.Apps.Get(verifyApp)
+			if app == nil {
+				logger.Fatalf("app %s is not configured", verifyApp)
+			}
+			apps = config.Apps{app}
+		}
+
+		results := verify.Apps(&cfg.Steam, apps)
+		if verifyRepair != "" {
+			repair(cmd, results)
+		}
+
+		var err error
+		switch verifyOut {
+		case "":
+			err = printVerifyTable(os.Stdout, results)
+		case output.JSONL:
+			for _, r := range results {
+				if err = verifyOut.Write(os.Stdout, r); err != nil {
+					break
+				}
+			}
+		default:
+			err = verifyOut.Write(os.Stdout, results)
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to print results")
+		}
+
+		for _, r := range results {
+			if !r.OK() && !r.Repaired {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+var (
+	verifyOut    output.Output
+	verifyRepair string
+	verifyApp    string
+)
+
+// repair repairs all mods with differences, failed repairs are recorded in the results
+func repair(cmd *cobra.Command, results []*verify.Result) {
+	var broken []*verify.Result
+	for _, r := range results {
+		if !r.OK() {
+			broken = append(broken, r)
+		}
+	}
+	if len(broken) == 0 {
+		return
+	}
+
+	if verifyRepair == repairCopy {
+		for _, r := range broken {
+			if r.Error != "" {
+				r.RepairErr = fmt.Sprintf("cannot copy: %s", r.Error)
+				continue
+			}
+			logger.WithField("workshop_id", r.WorkshopID).WithField("destination", r.Path).Info("copying mod")
+			var opts path.Options
+			if app := cfg.Apps.Get(r.AppID); app != nil {
+				opts = app.InstallOptions()
+			}
+			if err := path.Install(r.Source, r.Path, opts); err != nil {
+				r.RepairErr = err.Error()
+				continue
+			}
+			r.Repaired = true
+		}
+		return
+	}
+
+	ids := make([]string, 0, len(broken))
+	for _, r := range broken {
+		ids = append(ids, r.WorkshopID)
+	}
+	// the listed mods are always downloaded, even if they are installed in the latest version
+	summary, err := runner.NewRunner().Run(cmd.Context(), &cfg, runner.Options{WorkshopIDs: ids, Validate: true})
+	if err == nil && (summary == nil || summary.Items == 0) {
+		err = errors.New("nothing was downloaded")
+	}
+	if err != nil {
+		logger.WithError(err).Error("failed to download mods again")
+	}
+	for _, r := range broken {
+		var result *event.Result
+		if summary != nil {
+			result = summary.Result(r.WorkshopID)
+		}
+		switch {
+		case result != nil && result.Status == event.Copied:
+			r.Repaired = true
+		case result != nil:
+			r.RepairErr = result.Error
+		case err != nil:
+			r.RepairErr = err.Error()
+		default:
+			r.RepairErr = "mod was not downloaded"
+		}
+	}
+}
+
+// printVerifyTable prints a table of the results and the differing files of every mod
+func printVerifyTable(out io.Writer, results []*verify.Result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APP\tMOD\tNAME\tFILES\tSTATUS\tREPAIR")
+	for _, r := range results {
+		repaired := ""
+		switch {
+		case r.Repaired:
+			repaired = "repaired"
+		case r.RepairErr != "":
+			repaired = "failed: " + r.RepairErr
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", r.AppID, r.WorkshopID, r.Name, r.Files, r.Status(), repaired)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.OK() {
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "\n%s (%s):\n", r.WorkshopID, r.Source)
+		if r.Error != "" {
+			_, _ = fmt.Fprintf(out, "  error     %s\n", r.Error)
+		}
+		for _, f := range r.Missing {
+			_, _ = fmt.Fprintf(out, "  missing   %s\n", f)
+		}
+		for _, f := range r.Modified {
+			_, _ = fmt.Fprintf(out, "  modified  %s\n", f)
+		}
+		for _, f := range r.Extra {
+			_, _ = fmt.Fprintf(out, "  extra     %s\n", f)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().VarP(&verifyOut, "output", "o", "print the results as yaml, json or jsonl instead of a table")
+	verifyCmd.Flags().StringVar(&verifyRepair, "repair", "", "repair mods with differences (copy or validate)")
+	verifyCmd.Flags().StringVar(&verifyApp, "app", "", "verify only the mods of this app")
+}
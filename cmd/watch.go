@@ -0,0 +1,89 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"errors"
+	"github.com/Cehir/steam-workshop-downloader/pkg/daemon"
+	"github.com/Cehir/steam-workshop-downloader/pkg/runner"
+	"github.com/fsnotify/fsnotify"
+	logger "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:     "watch",
+	Aliases: []string{"daemon"},
+	Short:   "Keep the configured mods up to date",
+	Long: `Checks the workshop for updated mods on an interval and downloads only the mods
+that were updated since they were installed. Two downloads never run at the same time.
+
+The config file is reloaded when it changes. With --listen the status is served as JSON on /status
+and metrics in the Prometheus text format on /metrics.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if watchInterval <= 0 {
+			logger.WithField("interval", watchInterval).Fatal("interval must be positive")
+		}
+		loadConfig(false)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		r := runner.NewRunner()
+		m := newMetrics(r)
+		d := daemon.NewDaemon(&cfg, r, watchInterval)
+
+		// reload the config file on change, an invalid config keeps the previous one
+		viper.OnConfigChange(func(e fsnotify.Event) {
+			logger.WithField("file", e.Name).Info("config file changed")
+			c, err := reloadConfig()
+			if err != nil {
+				logger.WithError(err).Error("failed to reload config, keeping previous config")
+				return
+			}
+			d.SetConfig(c)
+		})
+		if viper.ConfigFileUsed() != "" {
+			viper.WatchConfig()
+		}
+
+		if watchListen != "" {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", m.Handler())
+			mux.Handle("/", d.Handler())
+			server := &http.Server{Addr: watchListen, Handler: mux}
+			go func() {
+				logger.WithField("address", watchListen).Info("serving status")
+				if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.WithError(err).Fatal("failed to serve status")
+				}
+			}()
+			defer func() {
+				_ = server.Close()
+			}()
+		}
+
+		d.Run(ctx)
+		logger.Info("stopped watching")
+	},
+}
+
+var (
+	watchInterval = time.Hour
+	watchListen   string
+)
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", watchInterval, "interval between two checks for updates")
+	watchCmd.Flags().StringVar(&watchListen, "listen", "", "address to serve the status and metrics on, e.g. 127.0.0.1:8080")
+}
@@ -0,0 +1,219 @@
+// Package api serves a small REST API to list the configured apps, trigger downloads and inspect their results.
+//
+//	GET  /apps                 list all configured apps with their mods
+//	GET  /apps/{id}            get a single app
+//	POST /apps/{id}/download   download the mods of a single app
+//	POST /download             download the mods of all apps
+//	GET  /events               stream the events of all runs as JSON lines
+//	GET  /report               get the summary of the last run
+//	GET  /status               get the status of the runner
+//
+// Additional handlers like metrics can be served with Server.Handle.
+//
+// The download endpoints accept ?updated=true to download only updated mods.
+// They return 202 when the run was started and 409 if another run is in progress.
+// Every request needs the header "Authorization: Bearer <token>".
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/runner"
+	"net/http"
+	"strings"
+)
+
+// Server serves the REST API
+type Server struct {
+	ctx    context.Context
+	cfg    *config.Config
+	runner *runner.Runner
+	token  string
+	hub    *hub
+	extra  map[string]http.Handler
+}
+
+// NewServer returns a server for the given config and runner.
+// Runs started by the server are cancelled when ctx is done.
+func NewServer(ctx context.Context, cfg *config.Config, r *runner.Runner, token string) *Server {
+	s := &Server{
+		ctx:    ctx,
+		cfg:    cfg,
+		runner: r,
+		token:  token,
+		hub:    newHub(),
+		extra:  make(map[string]http.Handler),
+	}
+	r.OnEvent(s.hub.Publish)
+	return s
+}
+
+// Handle serves an additional handler for pattern, it has to be called before Handler
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.extra[pattern] = h
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for pattern, h := range s.extra {
+		mux.Handle(pattern, h)
+	}
+	mux.HandleFunc("/apps", s.method(http.MethodGet, s.apps))
+	mux.HandleFunc("/apps/", s.app)
+	mux.HandleFunc("/download", s.method(http.MethodPost, s.download))
+	mux.HandleFunc("/events", s.method(http.MethodGet, s.events))
+	mux.HandleFunc("/report", s.method(http.MethodGet, s.report))
+	mux.HandleFunc("/status", s.method(http.MethodGet, s.status))
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// method rejects requests with another method than m
+func (s *Server) method(m string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			writeError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// App is an app in responses, it leaves out the server, hooks and backups of the config
+// so commands and passwords like the RCON password are never served
+type App struct {
+	AppID   string        `json:"id"`                // Steam App ID
+	Name    string        `json:"name"`              // Name of the game
+	Path    string        `json:"path,omitempty"`    // Path to the mod directory
+	Install string        `json:"install,omitempty"` // How mods are installed
+	Mods    []*config.Mod `json:"mods,omitempty"`    // Mods of the game
+}
+
+func newApp(app *config.App) *App {
+	return &App{
+		AppID:   app.AppID,
+		Name:    app.Name,
+		Path:    app.Path,
+		Install: app.Install,
+		Mods:    app.Mods,
+	}
+}
+
+func (s *Server) apps(w http.ResponseWriter, r *http.Request) {
+	apps := make([]*App, 0, len(s.cfg.Apps))
+	for _, app := range s.cfg.Apps {
+		apps = append(apps, newApp(app))
+	}
+	writeJSON(w, http.StatusOK, apps)
+}
+
+// app serves /apps/{id} and /apps/{id}/download
+func (s *Server) app(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/apps/"), "/")
+	app := s.cfg.Apps.Get(id)
+	if app == nil {
+		writeError(w, http.StatusNotFound, "app not found")
+		return
+	}
+
+	switch action {
+	case "":
+		s.method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, newApp(app))
+		})(w, r)
+	case "download":
+		s.method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+			s.start(w, r, app.AppID)
+		})(w, r)
+	default:
+		writeError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+}
+
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	s.start(w, r, "")
+}
+
+// start starts a run in the background, the response does not wait for the run to finish
+func (s *Server) start(w http.ResponseWriter, r *http.Request, appID string) {
+	opts := runner.Options{
+		OnlyUpdated: r.URL.Query().Get("updated") == "true",
+		AppID:       appID,
+	}
+	if err := s.runner.Start(s.ctx, s.cfg, opts); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+// events streams the events of all runs as JSON lines until the client disconnects
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	c := s.hub.Subscribe()
+	defer s.hub.Unsubscribe(c)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	o := output.JSONL
+	write := o.Events(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case e := <-c:
+			write(e)
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	last := s.runner.Status().Last
+	if last == nil {
+		writeError(w, http.StatusNotFound, "no run finished yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, last)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.runner.Status())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	_ = e.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
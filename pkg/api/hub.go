@@ -0,0 +1,51 @@
+package api
+
+import (
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"sync"
+)
+
+// subscriberBuffer is the number of events buffered per subscriber, further events are dropped
+const subscriberBuffer = 256
+
+// hub distributes events to all subscribers
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[chan event.Event]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribers: make(map[chan event.Event]struct{}),
+	}
+}
+
+// Publish sends the event to all subscribers without blocking, it implements event.Handler
+func (h *hub) Publish(e event.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.subscribers {
+		select {
+		case c <- e:
+		default:
+			// subscriber is too slow, drop the event
+		}
+	}
+}
+
+// Subscribe returns a channel receiving all events until Unsubscribe is called
+func (h *hub) Subscribe() chan event.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := make(chan event.Event, subscriberBuffer)
+	h.subscribers[c] = struct{}{}
+	return c
+}
+
+// Unsubscribe removes the subscriber and closes its channel
+func (h *hub) Unsubscribe(c chan event.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, c)
+	close(c)
+}
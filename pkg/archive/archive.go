@@ -0,0 +1,383 @@
+// Package archive writes and extracts tar.gz, tar.zst and zip archives of folders
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Format of an archive
+type Format string
+
+const (
+	TarGz  Format = "tar.gz"
+	TarZst Format = "tar.zst"
+	Zip    Format = "zip"
+)
+
+var (
+	InvalidFormatErr = errors.New(`invalid archive format, must be "tar.gz", "tar.zst" or "zip"`)
+	UnknownFormatErr = errors.New("unknown archive format")
+)
+
+// String returns the string representation of the format
+// it is used to implement the flag.Value interface
+func (f *Format) String() string {
+	return string(*f)
+}
+
+// Set sets the format to the given value
+// it is used to implement the flag.Value interface
+func (f *Format) Set(v string) error {
+	switch v {
+	case "tar.gz", "tar.zst", "zip":
+		*f = Format(v)
+		return nil
+	default:
+		return InvalidFormatErr
+	}
+}
+
+// Type returns the type of the format
+// it is used to implement the flag.Value interface
+func (f *Format) Type() string {
+	return "format"
+}
+
+// FormatOf returns the format of an archive by the extension of its name, .tgz is tar.gz and .tzst is tar.zst
+func FormatOf(name string) (Format, error) {
+	switch lower := strings.ToLower(name); {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return TarGz, nil
+	case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"):
+		return TarZst, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return Zip, nil
+	}
+	return "", fmt.Errorf("%w: %s", UnknownFormatErr, name)
+}
+
+// Writer adds folders and files to an archive
+type Writer struct {
+	format Format
+	c      io.WriteCloser // compressor of a tar archive
+	tw     *tar.Writer
+	zw     *zip.Writer
+}
+
+// NewWriter returns a writer of an archive in the format to w, it has to be closed
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	switch format {
+	case TarGz:
+		gz := gzip.NewWriter(w)
+		return &Writer{format: format, c: gz, tw: tar.NewWriter(gz)}, nil
+	case TarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &Writer{format: format, c: zw, tw: tar.NewWriter(zw)}, nil
+	case Zip:
+		return &Writer{format: format, zw: zip.NewWriter(w)}, nil
+	}
+	return nil, InvalidFormatErr
+}
+
+// Close writes the end of the archive, it does not close the underlying writer
+func (w *Writer) Close() error {
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.c.Close()
+}
+
+// Add adds a folder or a regular file with the content of r as name, names use slashes
+func (w *Writer) Add(name string, info fs.FileInfo, r io.Reader) error {
+	if info.IsDir() {
+		name = strings.TrimSuffix(name, "/") + "/"
+	}
+	var dst io.Writer
+	if w.zw != nil {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if !info.IsDir() {
+			hdr.Method = zip.Deflate
+		}
+		if dst, err = w.zw.CreateHeader(hdr); err != nil {
+			return err
+		}
+	} else {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if err := w.tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		dst = w.tw
+	}
+	if info.IsDir() || r == nil {
+		return nil
+	}
+	_, err := io.Copy(dst, r)
+	return err
+}
+
+// AddFile adds the regular file at path as name
+func (w *Writer) AddFile(name, path string, info fs.FileInfo) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	return w.Add(name, info, f)
+}
+
+// AddBytes adds a regular file with the content b as name
+func (w *Writer) AddBytes(name string, b []byte) error {
+	return w.Add(name, bytesInfo{name: filepath.Base(name), size: int64(len(b)), modTime: time.Now()}, bytes.NewReader(b))
+}
+
+// AddDir adds the folders and regular files in dir with the name prefix, links are followed
+func (w *Writer) AddDir(dir, prefix string) error {
+	return Walk(dir, func(rel, path string, info fs.FileInfo) error {
+		name := prefix + rel
+		if info.IsDir() {
+			return w.Add(name, info, nil)
+		}
+		return w.AddFile(name, path, info)
+	})
+}
+
+// Walk calls fn for the folders and regular files in dir with their path relative to dir using slashes.
+// Links are followed, folders are visited before their content, loops are an error.
+func Walk(dir string, fn func(rel, path string, info fs.FileInfo) error) error {
+	return walk(dir, "", map[string]bool{}, fn)
+}
+
+func walk(dir, prefix string, visiting map[string]bool, fn func(rel, path string, info fs.FileInfo) error) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if visiting[real] {
+		return fmt.Errorf("%s: %w", dir, path.LoopErr)
+	}
+	visiting[real] = true
+	defer delete(visiting, real)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		rel := prefix + entry.Name()
+		// follow links
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			if err := fn(rel, p, info); err != nil {
+				return err
+			}
+			if err := walk(p, rel+"/", visiting, fn); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := fn(rel, p, info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Extract extracts the folders and regular files of the archive src to dst, the format is detected by its content.
+// Entries escaping dst are rejected, modification times are preserved.
+// The archive is extracted to a temporary folder next to dst first and installed like path.Install,
+// so a broken archive leaves dst unchanged and links in dst are replaced instead of written through.
+func Extract(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".extract-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	if err := extract(src, tmp); err != nil {
+		return err
+	}
+	// hard links of the temporary files on the same volume, copies otherwise
+	return path.Install(tmp, dst, path.Options{Mode: path.Hardlink})
+}
+
+// extract extracts the archive src to the new folder dst
+func extract(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	magic, _ := bufio.NewReader(f).Peek(4)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	e := &extractor{dst: dst, dirs: map[string]time.Time{}}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		info, statErr := f.Stat()
+		if statErr != nil {
+			return statErr
+		}
+		err = e.zip(f, info.Size())
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(f); err == nil {
+			err = e.tar(gz)
+		}
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(f); err == nil {
+			err = e.tar(zr)
+			zr.Close()
+		}
+	default:
+		err = fmt.Errorf("%w: %s", UnknownFormatErr, src)
+	}
+	if err != nil {
+		return err
+	}
+
+	// folder times are set at the end, extracting their files changes them
+	for dir, t := range e.dirs {
+		if err := os.Chtimes(dir, t, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractor extracts entries to dst and remembers the times of the folders
+type extractor struct {
+	dst  string
+	dirs map[string]time.Time
+}
+
+// tar extracts the uncompressed tar archive r
+func (e *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg:
+			if err := e.entry(hdr.Name, hdr.FileInfo(), tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (e *extractor) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		info := zf.FileInfo()
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = e.entry(zf.Name, info, rc)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry extracts a folder or a regular file with the content of r
+func (e *extractor) entry(name string, info fs.FileInfo, r io.Reader) error {
+	outpath := filepath.Join(e.dst, filepath.FromSlash(strings.TrimSuffix(name, "/")))
+	if !path.Within(e.dst, outpath) {
+		return fmt.Errorf("%s: %w", name, path.EscapeErr)
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(outpath, info.Mode().Perm()|0o700); err != nil {
+			return err
+		}
+		e.dirs[outpath] = info.ModTime()
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outpath), 0o755); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(outpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fh, r); err != nil {
+		_ = fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(outpath, info.ModTime(), info.ModTime())
+}
+
+// bytesInfo is the file info of a file added from memory
+type bytesInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i bytesInfo) Name() string       { return i.name }
+func (i bytesInfo) Size() int64        { return i.size }
+func (i bytesInfo) Mode() fs.FileMode  { return 0o644 }
+func (i bytesInfo) ModTime() time.Time { return i.modTime }
+func (i bytesInfo) IsDir() bool        { return false }
+func (i bytesInfo) Sys() any           { return nil }
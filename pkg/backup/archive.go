@@ -0,0 +1,37 @@
+package backup
+
+import (
+	"github.com/Cehir/steam-workshop-downloader/pkg/archive"
+	"os"
+)
+
+// writeArchive writes the content of src to the archive dst in the format and returns the size of the archive.
+// Links are followed, the archive contains the files they point to.
+func writeArchive(src, dst string, format archive.Format) (int64, error) {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	w, err := archive.NewWriter(f, format)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.AddDir(src, ""); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,329 @@
+// Package backup takes snapshots of the mods folders of apps before updates and restores them.
+//
+// Snapshots of an app are stored in <dir>/<app id> of its backup config, each as tar.zst or tar.gz archive or
+// folder of hard links next to a JSON file describing it. A snapshot without JSON file is incomplete.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/archive"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	logger "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// formats of snapshots
+const (
+	TarZst   = "tar.zst"  // compressed archive
+	TarGz    = "tar.gz"   // compressed archive, larger and slower than tar.zst
+	Hardlink = "hardlink" // folder of hard links to the files, uses no extra space for unchanged files
+)
+
+// DefaultKeep is the number of snapshots kept if the backup config has no limit
+const DefaultKeep = 5
+
+const timeFormat = "20060102-150405"
+
+var (
+	NotFoundErr      = errors.New("snapshot not found")
+	NotConfiguredErr = errors.New("backup is not configured")
+)
+
+// Snapshot is a copy of the path of an app at a point in time
+type Snapshot struct {
+	ID      string        `json:"id" yaml:"id"`               // unique id of the snapshot, <app id>-<time>
+	AppID   string        `json:"app_id" yaml:"app_id"`       // Steam App ID
+	Format  string        `json:"format" yaml:"format"`       // tar.zst, tar.gz or hardlink
+	Created time.Time     `json:"created" yaml:"created"`     // time the snapshot was taken
+	Source  string        `json:"source" yaml:"source"`       // path of the app
+	Path    string        `json:"path" yaml:"path"`           // archive or folder of the snapshot
+	Bytes   int64         `json:"bytes" yaml:"bytes"`         // size of the archive or of the files in the folder
+	Mods    []*state.Item `json:"mods,omitempty" yaml:"mods"` // installed mods of the app according to the state
+}
+
+// dir returns the folder of the snapshots of the app
+func dir(app *config.App) string {
+	return filepath.Join(app.Backup.Dir, app.AppID)
+}
+
+// format returns the configured format of the snapshots of the app
+func format(app *config.App) string {
+	if app.Backup.Format == "" {
+		return TarZst
+	}
+	return app.Backup.Format
+}
+
+// Take takes a snapshot of the path of the app and removes snapshots beyond the retention of its backup config.
+// It returns a nil snapshot if the path is empty or missing.
+func Take(app *config.App, st *state.State) (*Snapshot, error) {
+	snap, err := Create(app, st)
+	if err != nil || snap == nil {
+		return snap, err
+	}
+	removed, err := Prune(app)
+	if err != nil {
+		logger.WithError(err).WithField("app_id", app.AppID).Error("failed to remove old snapshots")
+	}
+	for _, s := range removed {
+		logger.WithField("id", s.ID).Debug("removed old snapshot")
+	}
+	return snap, nil
+}
+
+// Create takes a snapshot of the path of the app with the installed mods of the app in the state.
+// It returns a nil snapshot if the path is empty or missing.
+func Create(app *config.App, st *state.State) (*Snapshot, error) {
+	if app.Backup == nil {
+		return nil, NotConfiguredErr
+	}
+	entries, err := os.ReadDir(app.Path)
+	if errors.Is(err, os.ErrNotExist) || err == nil && len(entries) == 0 {
+		logger.WithField("path", app.Path).Debug("nothing to back up")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", app.Path, err)
+	}
+	if err := os.MkdirAll(dir(app), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	name := now.Format(timeFormat)
+	for i := 2; exists(filepath.Join(dir(app), name+".json")); i++ {
+		name = fmt.Sprintf("%s-%d", now.Format(timeFormat), i)
+	}
+	snap := &Snapshot{
+		ID:      app.AppID + "-" + name,
+		AppID:   app.AppID,
+		Format:  format(app),
+		Created: now,
+		Source:  app.Path,
+		Path:    filepath.Join(dir(app), name),
+	}
+	for _, item := range st.Items {
+		if item.AppID == app.AppID {
+			snap.Mods = append(snap.Mods, item)
+		}
+	}
+	sort.Slice(snap.Mods, func(i, j int) bool { return snap.Mods[i].WorkshopID < snap.Mods[j].WorkshopID })
+
+	// the snapshot is written to a temporary name and renamed when complete
+	tmp := filepath.Join(dir(app), "."+name+".tmp")
+	switch snap.Format {
+	case Hardlink:
+		err = path.Install(app.Path, tmp, path.Options{Mode: path.Hardlink, Symlinks: path.Follow})
+		if err == nil {
+			snap.Bytes, err = path.Size(tmp)
+		}
+	default:
+		snap.Path += "." + snap.Format
+		snap.Bytes, err = writeArchive(app.Path, tmp, archive.Format(snap.Format))
+	}
+	if err == nil {
+		err = os.Rename(tmp, snap.Path)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, fmt.Errorf("failed to take snapshot of %s: %w", app.Path, err)
+	}
+
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir(app), name+".json"), b, 0o644); err != nil {
+		_ = os.RemoveAll(snap.Path)
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	logger.WithFields(logger.Fields{
+		"id":    snap.ID,
+		"path":  snap.Path,
+		"bytes": snap.Bytes,
+	}).Info("took snapshot")
+	return snap, nil
+}
+
+// List returns the snapshots of the app, newest first
+func List(app *config.App) ([]*Snapshot, error) {
+	if app.Backup == nil {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir(app), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var snaps []*Snapshot
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		snap := &Snapshot{}
+		if err := json.Unmarshal(b, snap); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot %s: %w", file, err)
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Created.After(snaps[j].Created) })
+	return snaps, nil
+}
+
+// Find returns the app and its snapshot with the id
+func Find(apps config.Apps, id string) (*config.App, *Snapshot, error) {
+	for _, app := range apps {
+		if !strings.HasPrefix(id, app.AppID+"-") {
+			continue
+		}
+		snaps, err := List(app)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, snap := range snaps {
+			if snap.ID == id {
+				return app, snap, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %s", NotFoundErr, id)
+}
+
+// Prune removes the snapshots of the app beyond the number to keep or older than the maximum age.
+// It returns the removed snapshots.
+func Prune(app *config.App) ([]*Snapshot, error) {
+	snaps, err := List(app)
+	if err != nil {
+		return nil, err
+	}
+	keep := app.Backup.Keep
+	if keep == 0 {
+		keep = DefaultKeep
+	}
+
+	var removed []*Snapshot
+	var errs path.Errors
+	for i, snap := range snaps {
+		expired := app.Backup.MaxAge > 0 && time.Since(snap.Created) > app.Backup.MaxAge
+		if i < keep && !expired {
+			continue
+		}
+		if err := Remove(snap); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, snap)
+	}
+	if len(errs) > 0 {
+		return removed, errs
+	}
+	return removed, nil
+}
+
+// Remove removes the snapshot, its description is removed last
+func Remove(snap *Snapshot) error {
+	if err := os.RemoveAll(snap.Path); err != nil {
+		return fmt.Errorf("failed to remove snapshot %s: %w", snap.ID, err)
+	}
+	if err := os.Remove(description(snap)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove snapshot %s: %w", snap.ID, err)
+	}
+	return nil
+}
+
+// description returns the path of the JSON file of the snapshot
+func description(snap *Snapshot) string {
+	if snap.Format == Hardlink {
+		return snap.Path + ".json"
+	}
+	return strings.TrimSuffix(snap.Path, "."+snap.Format) + ".json"
+}
+
+// Restore replaces the content of the path of the app with the snapshot and records the mods of the snapshot
+// as installed mods of the app in the state. The snapshot is extracted next to the path first,
+// the path is only changed if the snapshot is complete and then swapped with the extracted folder.
+func Restore(app *config.App, snap *Snapshot, st *state.State) error {
+	if err := os.MkdirAll(app.Path, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(app.Path), "."+filepath.Base(app.Path)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", snap.ID, err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	switch snap.Format {
+	case Hardlink:
+		err = path.Install(snap.Path, tmp, path.Options{Mode: path.Hardlink})
+	default:
+		err = archive.Extract(snap.Path, tmp)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", snap.ID, err)
+	}
+
+	if err := replace(app.Path, tmp); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", snap.ID, err)
+	}
+
+	for id, item := range st.Items {
+		if item.AppID == app.AppID {
+			st.Delete(id)
+		}
+	}
+	for _, item := range snap.Mods {
+		st.Set(item)
+	}
+	logger.WithField("id", snap.ID).WithField("path", app.Path).Info("restored snapshot")
+	return nil
+}
+
+// replace replaces the folder dst with the folder src. dst is renamed aside first and renamed back
+// if src cannot take its place, it is removed once src is in place.
+func replace(dst, src string) error {
+	info, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(src, info.Mode().Perm()); err != nil {
+		return err
+	}
+	// a free name next to dst
+	old, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".old-*")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, old); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if rbErr := os.Rename(old, dst); rbErr != nil {
+			return fmt.Errorf("%w, the previous content is in %s", err, old)
+		}
+		return err
+	}
+	if err := os.RemoveAll(old); err != nil {
+		logger.WithError(err).WithField("path", old).Warn("failed to remove previous content")
+	}
+	return nil
+}
+
+// exists returns true if a file exists at path
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
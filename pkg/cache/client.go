@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/verify"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MissErr is returned if the cache server does not have an item
+var MissErr = errors.New("item is not in the cache")
+
+const (
+	dialTimeout   = 10 * time.Second // timeout to connect to the server
+	headerTimeout = 2 * time.Minute  // timeout of the response headers, the server hashes an item before it answers
+	stallTimeout  = 30 * time.Second // a response body without data for this long is aborted
+)
+
+// Client fetches items from a cache server
+type Client struct {
+	URL   string       // base URL of the server e.g. http://cache:8080
+	Token string       // bearer token, empty if the server has none
+	HTTP  *http.Client // client used for requests, downloads are aborted if they stall
+}
+
+func NewClient(url, token string) *Client {
+	return &Client{
+		URL:   strings.TrimSuffix(url, "/"),
+		Token: token,
+		HTTP: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+				TLSHandshakeTimeout:   dialTimeout,
+				ResponseHeaderTimeout: headerTimeout,
+				IdleConnTimeout:       90 * time.Second,
+			},
+		},
+	}
+}
+
+// get requests path from the server, the body of the response has to be closed
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return c.HTTP.Do(req)
+}
+
+// Item returns the manifest of an item, MissErr if the server does not have it
+func (c *Client) Item(ctx context.Context, appID, workshopID string) (*Item, error) {
+	resp, err := c.get(ctx, "/v1/items/"+appID+"/"+workshopID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request cached item: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, MissErr
+	default:
+		return nil, fmt.Errorf("failed to request cached item: %s", resp.Status)
+	}
+
+	item := &Item{}
+	if err := json.NewDecoder(resp.Body).Decode(item); err != nil {
+		return nil, fmt.Errorf("failed to decode cached item: %w", err)
+	}
+	return item, nil
+}
+
+// Fetch replaces dst with the files of the item. Files already in dst with the same content are reused,
+// the others are downloaded and checked against their SHA-256. dst is only replaced if all files were fetched.
+func (c *Client) Fetch(ctx context.Context, item *Item, dst string, progress func(done, total int64)) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".fetch-*")
+	if err != nil {
+		return err
+	}
+	if err := c.fetch(ctx, item, dst, tmp, progress); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (c *Client) fetch(ctx context.Context, item *Item, dst, tmp string, progress func(done, total int64)) error {
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return err
+	}
+	var done int64
+	for _, f := range item.Files {
+		outpath := filepath.Join(tmp, filepath.FromSlash(f.Path))
+		if !path.Within(tmp, outpath) || outpath == tmp {
+			return fmt.Errorf("%s: %w", f.Path, path.EscapeErr)
+		}
+		if err := os.MkdirAll(filepath.Dir(outpath), 0o755); err != nil {
+			return err
+		}
+
+		if !reuse(filepath.Join(dst, filepath.FromSlash(f.Path)), outpath, f) {
+			if err := c.download(ctx, f, outpath, func(n int64) {
+				if progress != nil {
+					progress(done+n, item.Bytes)
+				}
+			}); err != nil {
+				return fmt.Errorf("failed to fetch %s: %w", f.Path, err)
+			}
+		}
+		if err := os.Chtimes(outpath, f.ModTime, f.ModTime); err != nil {
+			return err
+		}
+		done += f.Size
+		if progress != nil {
+			progress(done, item.Bytes)
+		}
+	}
+	return nil
+}
+
+// reuse links the local file to outpath if it has the content of f
+func reuse(local, outpath string, f *File) bool {
+	info, err := os.Stat(local)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != f.Size {
+		return false
+	}
+	sum, err := verify.Hash(local)
+	if err != nil || hex.EncodeToString(sum) != f.SHA256 {
+		return false
+	}
+	return os.Link(local, outpath) == nil
+}
+
+// download writes the content of f to outpath and checks its SHA-256
+func (c *Client) download(ctx context.Context, f *File, outpath string, progress func(n int64)) error {
+	fileCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := c.get(fileCtx, "/v1/files/"+f.SHA256)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body := &stallReader{r: resp.Body, timer: time.AfterFunc(stallTimeout, cancel)}
+	defer body.timer.Stop()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+
+	fh, err := os.OpenFile(outpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	w := &countingWriter{w: io.MultiWriter(fh, h), progress: progress}
+	_, err = io.Copy(w, io.LimitReader(body, f.Size+1))
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil && fileCtx.Err() != nil && ctx.Err() == nil {
+		return fmt.Errorf("cache server sent no data for %s", stallTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	if w.n != f.Size || hex.EncodeToString(h.Sum(nil)) != f.SHA256 {
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
+
+// stallReader restarts timer on every read, the timer aborts the request when the server stalls
+type stallReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.timer.Reset(stallTimeout)
+	return n, err
+}
+
+// progressStep is the number of bytes between two progress reports of a download
+const progressStep = 1 << 20
+
+// countingWriter reports the number of bytes written every progressStep bytes
+type countingWriter struct {
+	w        io.Writer
+	n        int64
+	reported int64
+	progress func(n int64)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	if w.n-w.reported >= progressStep {
+		w.reported = w.n
+		w.progress(w.n)
+	}
+	return n, err
+}
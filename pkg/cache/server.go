@@ -0,0 +1,255 @@
+// Package cache shares the workshop content directory of steamcmd with other hosts over HTTP.
+//
+//	GET /v1/items/{app id}/{workshop id}   manifest of an item with the SHA-256 of its files
+//	GET /v1/files/{sha256}                 content of a file of an item
+//
+// Files are addressed by their content, clients fetch the manifest of an item first and
+// only the files they do not have yet. If a token is set, every request needs the header
+// "Authorization: Bearer <token>".
+package cache
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/manifest"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/verify"
+	logger "github.com/sirupsen/logrus"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Item is the manifest of a workshop item in the cache
+type Item struct {
+	AppID       string  `json:"app_id"`                 // Steam App ID
+	WorkshopID  string  `json:"workshop_id"`            // Steam Workshop ID
+	TimeUpdated int64   `json:"time_updated,omitempty"` // workshop unix time of the cached version, 0 if unknown
+	Bytes       int64   `json:"bytes"`                  // size of all files
+	Files       []*File `json:"files"`                  // files of the item folder
+}
+
+// File is a file of an item
+type File struct {
+	Path    string      `json:"path"`     // path relative to the item folder using slashes
+	SHA256  string      `json:"sha256"`   // hex SHA-256 of the content
+	Size    int64       `json:"size"`     // size of the content
+	Mode    fs.FileMode `json:"mode"`     // permissions
+	ModTime time.Time   `json:"mod_time"` // modification time
+}
+
+// Server serves the workshop content directory of steam
+type Server struct {
+	steam *config.Steam
+	token string
+
+	mu    sync.Mutex
+	items map[string]*entry // manifests by item folder
+	files map[string]*blob  // files by SHA-256
+}
+
+// entry is a manifest with the fingerprint of the folder it was computed for
+type entry struct {
+	fingerprint string
+	item        *Item
+}
+
+// blob is a file on disk with the size and time it had when it was hashed
+type blob struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// NewServer returns a server of the workshop content directory of steam, an empty token allows all requests
+func NewServer(steam *config.Steam, token string) *Server {
+	return &Server{
+		steam: steam,
+		token: token,
+		items: make(map[string]*entry),
+		files: make(map[string]*blob),
+	}
+}
+
+// Handler returns the HTTP handler of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/items/", s.get(s.serveItem))
+	mux.HandleFunc("/v1/files/", s.get(s.serveFile))
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the bearer token if a token is set
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// get rejects requests with another method than GET
+func (s *Server) get(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// serveItem serves /v1/items/{app id}/{workshop id}
+func (s *Server) serveItem(w http.ResponseWriter, r *http.Request) {
+	appID, workshopID, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/items/"), "/")
+	if !numeric(appID) || !numeric(workshopID) {
+		writeError(w, http.StatusNotFound, "item not found")
+		return
+	}
+
+	item, err := s.Item(appID, workshopID)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "item not found")
+		return
+	}
+	if err != nil {
+		logger.WithError(err).WithField("workshop_id", workshopID).Error("failed to read cached item")
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	logger.WithField("workshop_id", workshopID).WithField("remote", r.RemoteAddr).Debug("serving item")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+// serveFile serves /v1/files/{sha256} if the file did not change since it was hashed
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	sum := strings.TrimPrefix(r.URL.Path, "/v1/files/")
+	s.mu.Lock()
+	b, ok := s.files[sum]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	// the file may have been replaced by a link since it was hashed
+	if info, err := os.Lstat(b.path); err != nil || !info.Mode().IsRegular() || !path.Within(s.steam.ContentDir(), b.path) {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	f, err := os.Open(b.path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	info, err := f.Stat()
+	if err != nil || info.Size() != b.size || !info.ModTime().Equal(b.modTime) {
+		writeError(w, http.StatusNotFound, "file changed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", b.modTime, f)
+}
+
+// Item returns the manifest of a cached item, files are only hashed again if the folder changed.
+// Symbolic links are skipped so only files inside the content directory are served.
+// It returns an error wrapping os.ErrNotExist if the item is not cached.
+func (s *Server) Item(appID, workshopID string) (*Item, error) {
+	dir := s.steam.ModDir(appID, workshopID)
+	var files []*File
+	paths := map[*File]string{}
+	var fingerprint strings.Builder
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		f := &File{Path: rel, Size: info.Size(), Mode: info.Mode().Perm(), ModTime: info.ModTime()}
+		files = append(files, f)
+		paths[f] = p
+		_, _ = fmt.Fprintf(&fingerprint, "%s:%d:%d\n", rel, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	e, ok := s.items[dir]
+	s.mu.Unlock()
+	if ok && e.fingerprint == fingerprint.String() {
+		return e.item, nil
+	}
+
+	// hash without the lock, requests for other items are not blocked by large mods
+	item := &Item{AppID: appID, WorkshopID: workshopID, Files: files}
+	for _, f := range files {
+		sum, err := verify.Hash(paths[f])
+		if err != nil {
+			return nil, err
+		}
+		f.SHA256 = hex.EncodeToString(sum)
+		item.Bytes += f.Size
+	}
+	m, err := manifest.Load(s.steam.ManifestPath(appID))
+	if err != nil {
+		logger.WithError(err).WithField("app_id", appID).Warn("failed to read workshop manifest")
+	}
+	if i := m.Get(workshopID); i != nil {
+		item.TimeUpdated = i.TimeUpdated
+	}
+
+	s.mu.Lock()
+	for _, f := range files {
+		s.files[f.SHA256] = &blob{path: paths[f], size: f.Size, modTime: f.ModTime}
+	}
+	s.items[dir] = &entry{fingerprint: fingerprint.String(), item: item}
+	s.mu.Unlock()
+	logger.WithField("workshop_id", workshopID).WithField("files", len(files)).Debug("hashed cached item")
+	return item, nil
+}
+
+// numeric returns true if s is a non-empty string of digits
+func numeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,196 @@
+package clean
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"github.com/Cehir/steam-workshop-downloader/pkg/vdf"
+	logger "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reasons to remove a folder of the workshop cache
+const (
+	Installed    = "installed"    // the mod is installed at its destination
+	Unconfigured = "unconfigured" // the item is not in the config
+	Stale        = "stale"        // an unfinished download or temp folder of steamcmd
+)
+
+// Entry is a folder of the workshop cache that can be removed
+type Entry struct {
+	AppID      string `json:"app_id" yaml:"app_id"`                               // Steam App ID
+	WorkshopID string `json:"workshop_id,omitempty" yaml:"workshop_id,omitempty"` // Steam Workshop ID, empty for stale folders of an app
+	Reason     string `json:"reason" yaml:"reason"`                               // why the folder can be removed
+	Path       string `json:"path" yaml:"path"`                                   // folder in the workshop cache
+	Bytes      int64  `json:"bytes" yaml:"bytes"`                                 // size of the folder
+	Removed    bool   `json:"removed" yaml:"removed"`                             // the folder was removed
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"`             // error while removing the folder
+}
+
+// Plan returns the folders of the workshop cache of the configured apps that can be removed:
+// mods installed according to the state, items not in the config and stale downloads.
+// Apps that are not configured are never touched, installed mods of apps linking to the cache are kept.
+func Plan(cfg *config.Config, st *state.State) ([]*Entry, error) {
+	var entries []*Entry
+	workshop := filepath.Dir(cfg.Steam.ContentDir())
+	for _, app := range cfg.Apps {
+		dirs, err := readDir(filepath.Join(cfg.Steam.ContentDir(), app.AppID))
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			id := filepath.Base(dir)
+			e := &Entry{AppID: app.AppID, WorkshopID: id, Path: dir}
+			switch owner, _ := cfg.Apps.Find(id); {
+			case owner == nil || owner.AppID != app.AppID:
+				e.Reason = Unconfigured
+			case st.Get(id) != nil && app.Install != string(path.Symlink):
+				e.Reason = Installed
+			default:
+				continue
+			}
+			entries = append(entries, e)
+		}
+
+		for _, folder := range []string{"downloads", "temp"} {
+			dirs, err := readDir(filepath.Join(workshop, folder, app.AppID))
+			if err != nil {
+				return nil, err
+			}
+			for _, dir := range dirs {
+				entries = append(entries, &Entry{AppID: app.AppID, WorkshopID: filepath.Base(dir), Reason: Stale, Path: dir})
+			}
+		}
+	}
+
+	for _, e := range entries {
+		size, err := path.Size(e.Path)
+		if err != nil {
+			return nil, err
+		}
+		e.Bytes = size
+	}
+	return entries, nil
+}
+
+// Results returns the entries of the installed mods of a download run
+func Results(cfg *config.Config, installed []string) ([]*Entry, error) {
+	var entries []*Entry
+	for _, id := range installed {
+		app, _ := cfg.Apps.Find(id)
+		if app == nil || app.Install == string(path.Symlink) {
+			continue
+		}
+		dir := cfg.Steam.ModDir(app.AppID, id)
+		size, err := path.Size(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &Entry{AppID: app.AppID, WorkshopID: id, Reason: Installed, Path: dir, Bytes: size})
+	}
+	return entries, nil
+}
+
+// Remove removes the folders of the entries and the removed items from the workshop manifests of steamcmd,
+// steamcmd downloads them again instead of assuming they are installed. It returns the reclaimed bytes.
+func Remove(steam *config.Steam, entries []*Entry) (int64, error) {
+	var reclaimed int64
+	var errs []string
+	removed := map[string][]string{}
+	for _, e := range entries {
+		if err := os.RemoveAll(e.Path); err != nil {
+			e.Error = err.Error()
+			errs = append(errs, err.Error())
+			continue
+		}
+		e.Removed = true
+		reclaimed += e.Bytes
+		if e.Reason != Stale {
+			removed[e.AppID] = append(removed[e.AppID], e.WorkshopID)
+		}
+		logger.WithField("path", e.Path).WithField("reason", e.Reason).Debug("removed from workshop cache")
+	}
+
+	for appID, ids := range removed {
+		if err := forget(steam.ManifestPath(appID), ids); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return reclaimed, fmt.Errorf("failed to clean workshop cache: %s", strings.Join(errs, "; "))
+	}
+	return reclaimed, nil
+}
+
+// forget removes the items from the workshop manifest at path
+func forget(path string, ids []string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read workshop manifest: %w", err)
+	}
+	root, err := vdf.Unmarshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to parse workshop manifest %s: %w", path, err)
+	}
+
+	drop := map[string]bool{}
+	for _, id := range ids {
+		drop[id] = true
+	}
+	for _, key := range []string{"WorkshopItemsInstalled", "WorkshopItemDetails"} {
+		block := root.Path("AppWorkshop", key)
+		if block == nil {
+			continue
+		}
+		kept := block.Children[:0]
+		for _, kv := range block.Children {
+			if !drop[kv.Key] {
+				kept = append(kept, kv)
+			}
+		}
+		block.Children = kept
+	}
+
+	out := vdf.Marshal(root)
+	if bytes.Equal(out, b) {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0644); err != nil {
+		return fmt.Errorf("failed to write workshop manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write workshop manifest: %w", err)
+	}
+	return nil
+}
+
+// readDir returns the sub folders of dir, a missing dir has none
+func readDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
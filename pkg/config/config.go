@@ -1,14 +1,17 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Cehir/steam-workshop-downloader/pkg/path"
 	"github.com/go-playground/validator/v10"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 )
 
 var (
@@ -25,6 +28,10 @@ func init() {
 		}
 		return name
 	})
+	Validator.RegisterStructValidation(validateSteam, Steam{})
+	Validator.RegisterStructValidationCtx(validateConfig, Config{})
+	_ = Validator.RegisterValidation("steamid", validateID)
+	_ = Validator.RegisterValidationCtx("writable", validateWritable)
 }
 
 type Apps []*App
@@ -60,6 +67,74 @@ func (a *Apps) CmdArgs() []string {
 	return s
 }
 
+// ValidateCmdArgs returns the steamcmd args like CmdArgs, steamcmd validates the files of the mods
+func (a *Apps) ValidateCmdArgs() []string {
+	if a == nil {
+		return nil
+	}
+	var s []string
+	for _, app := range *a {
+		for _, mod := range app.Mods {
+			s = append(s, "+workshop_download_item", app.AppID, mod.WorkshopID, "validate")
+		}
+	}
+	return s
+}
+
+// Count returns the number of mods of all apps
+func (a *Apps) Count() int {
+	if a == nil {
+		return 0
+	}
+	n := 0
+	for _, app := range *a {
+		n += len(app.Mods)
+	}
+	return n
+}
+
+// Get returns the app with the given app id, nil if it is not configured
+func (a *Apps) Get(appID string) *App {
+	if a == nil {
+		return nil
+	}
+	for _, app := range *a {
+		if app.AppID == appID {
+			return app
+		}
+	}
+	return nil
+}
+
+// Find returns the app and mod with the given workshop id
+func (a *Apps) Find(workshopID string) (*App, *Mod) {
+	if a == nil {
+		return nil, nil
+	}
+	for _, app := range *a {
+		for _, mod := range app.Mods {
+			if mod.WorkshopID == workshopID {
+				return app, mod
+			}
+		}
+	}
+	return nil, nil
+}
+
+// WorkshopIDs returns the workshop ids of all mods
+func (a *Apps) WorkshopIDs() []string {
+	if a == nil {
+		return nil
+	}
+	var ids []string
+	for _, app := range *a {
+		for _, mod := range app.Mods {
+			ids = append(ids, mod.WorkshopID)
+		}
+	}
+	return ids
+}
+
 // Destinations returns a map of appID to destination path
 func (a *Apps) Destinations() map[string]string {
 	if a == nil {
@@ -73,24 +148,123 @@ func (a *Apps) Destinations() map[string]string {
 }
 
 type Config struct {
-	Steam Steam `json:"steam" mapstructure:"steam" validate:"required"`                        // Steam config
-	Apps  Apps  `json:"apps,omitempty" mapstructure:"apps" validate:"omitempty,dive,required"` // List of games with mods to download
+	Version       int             `json:"version,omitempty" mapstructure:"version"`                                                // Version of the config format, older versions are migrated
+	Steam         Steam           `json:"steam" mapstructure:"steam" validate:"required"`                                          // Steam config
+	Apps          Apps            `json:"apps,omitempty" mapstructure:"apps" validate:"omitempty,dive,required"`                   // List of games with mods to download
+	State         string          `json:"state,omitempty" mapstructure:"state"`                                                    // Path to the state file of installed mods
+	Notifications []*Notification `json:"notifications,omitempty" mapstructure:"notifications" validate:"omitempty,dive,required"` // Notifications sent after a download run
+	Hooks         *Hooks          `json:"hooks,omitempty" mapstructure:"hooks" validate:"omitempty"`                               // Commands run for all apps
+	Space         string          `json:"space,omitempty" mapstructure:"space" validate:"omitempty,oneof=fail warn off"`           // Disk space check before downloading: fail (default), warn or off
 }
 
+// disk space checks before downloading
+const (
+	SpaceFail = "fail" // refuse to download without enough space, the default
+	SpaceWarn = "warn" // only log volumes without enough space
+	SpaceOff  = "off"  // do not check
+)
+
+// sources of mods
+const (
+	SourceSteamCmd = "steamcmd" // download mods with steamcmd, the default
+	SourceMirror   = "mirror"   // copy mods from a local mirror without steamcmd or network
+	SourceCache    = "cache"    // fetch mods from a cache server, download them with steamcmd if it does not have them
+)
+
 type Steam struct {
-	Login Login  `json:"login" mapstructure:"login" validate:"required"`  // Login credentials
-	Cmd   string `json:"cmd" mapstructure:"cmd" validate:"required,file"` // SteamCMD path e.g. /usr/bin/steamcmd
+	Login   Login  `json:"login" mapstructure:"login" validate:"required"`                                          // Login credentials
+	Cmd     string `json:"cmd" mapstructure:"cmd" validate:"required"`                                              // SteamCMD path e.g. /usr/bin/steamcmd, not needed for a mirror
+	Content string `json:"content,omitempty" mapstructure:"content"`                                                // Workshop content directory of SteamCMD, default is steamapps/workshop/content next to cmd
+	Clean   bool   `json:"clean,omitempty" mapstructure:"clean"`                                                    // Remove mods from the workshop content directory after they were copied
+	Source  string `json:"source,omitempty" mapstructure:"source" validate:"omitempty,oneof=steamcmd mirror cache"` // Where mods come from: steamcmd (default), mirror or cache
+	Mirror  string `json:"mirror,omitempty" mapstructure:"mirror" validate:"omitempty,dir"`                         // Local mirror laid out like steamapps with workshop/content/<appid>/<id>, e.g. rsynced from another host
+	Cache   *Cache `json:"cache,omitempty" mapstructure:"cache" validate:"omitempty"`                               // Cache server of the cache source
+}
+
+// Cache is a server sharing its workshop cache, see cache serve
+type Cache struct {
+	URL   string `json:"url" mapstructure:"url" validate:"required,url"` // URL of the server e.g. http://cache:8081
+	Token string `json:"token,omitempty" mapstructure:"token"`           // Token of the server
+}
+
+// Offline returns true if mods are copied from a local mirror instead of downloaded
+func (s *Steam) Offline() bool {
+	return s.Source == SourceMirror
+}
+
+// validateSteam checks steamcmd only if it is used and requires the mirror or cache of their source
+func validateSteam(sl validator.StructLevel) {
+	s := sl.Current().Interface().(Steam)
+	if s.Offline() {
+		if s.Mirror == "" {
+			sl.ReportError(s.Mirror, "mirror", "Mirror", "required", "")
+		}
+		return
+	}
+	if s.Source == SourceCache && s.Cache == nil {
+		sl.ReportError(s.Cache, "cache", "Cache", "required", "")
+	}
+	if info, err := os.Stat(s.Cmd); err != nil || info.IsDir() {
+		sl.ReportError(s.Cmd, "cmd", "Cmd", "file", "")
+	}
+}
+
+// ContentDir returns the workshop content directory of steamcmd, of the mirror for the mirror source
+func (s *Steam) ContentDir() string {
+	if s.Offline() {
+		return filepath.Join(s.Mirror, "workshop", "content")
+	}
+	if s.Content != "" {
+		return s.Content
+	}
+	return filepath.Join(filepath.Dir(s.Cmd), "steamapps", "workshop", "content")
+}
+
+// ManifestPath returns the path of the workshop manifest of steamcmd for the app
+func (s *Steam) ManifestPath(appID string) string {
+	return filepath.Join(filepath.Dir(s.ContentDir()), "appworkshop_"+appID+".acf")
+}
+
+// ModDir returns the directory of a mod in the workshop content directory, its mods folder is copied to the app path
+func (s *Steam) ModDir(appID, workshopID string) string {
+	return filepath.Join(s.ContentDir(), appID, workshopID)
 }
 
 // Validate validates the config
 func (c *Config) Validate() error {
+	return c.ValidateContext(context.Background())
+}
+
+// ValidateContext validates the config, with workshop items in ctx (see WithWorkshop) the mods are also checked online,
+// with WithWritable in ctx files are created to check that app paths are writable
+func (c *Config) ValidateContext(ctx context.Context) error {
 	cp, err := Path.Absolute(c.Steam.Cmd)
 	if err != nil {
 		return err
 	}
 	c.Steam.Cmd = cp
 
-	return Validator.Struct(c)
+	return Validator.StructCtx(ctx, c)
+}
+
+// Filter returns a copy of the config with only the mods for which keep returns true.
+// Apps without mods left are removed.
+func (c *Config) Filter(keep func(app *App, mod *Mod) bool) *Config {
+	filtered := *c
+	filtered.Apps = nil
+	for _, app := range c.Apps {
+		a := *app
+		a.Mods = nil
+		for _, mod := range app.Mods {
+			if keep(app, mod) {
+				a.Mods = append(a.Mods, mod)
+			}
+		}
+		if len(a.Mods) > 0 {
+			filtered.Apps = append(filtered.Apps, &a)
+		}
+	}
+	return &filtered
 }
 
 type ModPath struct {
@@ -164,10 +338,23 @@ func (l *Login) CmdArgs() []string {
 }
 
 type App struct {
-	Name  string `json:"name" mapstructure:"name"`                                              // Name of the game
-	AppID string `json:"id" mapstructure:"id" validate:"required"`                              // Steam App ID
-	Path  string `json:"path,omitempty" mapstructure:"path" validate:"required,dir"`            // Path to the mod directory
-	Mods  []*Mod `json:"mods,omitempty" mapstructure:"mods" validate:"omitempty,dive,required"` // List of mods to download for the game
+	Name     string  `json:"name" mapstructure:"name"`                                                                          // Name of the game
+	AppID    string  `json:"id" mapstructure:"id" validate:"required,steamid"`                                                  // Steam App ID
+	Path     string  `json:"path,omitempty" mapstructure:"path" validate:"required,writable"`                                   // Path to the mod directory
+	Install  string  `json:"install,omitempty" mapstructure:"install" validate:"omitempty,oneof=copy hardlink reflink symlink"` // How mods are installed: copy (default), hardlink, reflink or symlink
+	Symlinks string  `json:"symlinks,omitempty" mapstructure:"symlinks" validate:"omitempty,oneof=follow internal skip"`        // Links inside mods: internal (default) keeps links within the mod, follow copies their targets, skip ignores them
+	Mods     []*Mod  `json:"mods,omitempty" mapstructure:"mods" validate:"omitempty,dive,required"`                             // List of mods to download for the game
+	Hooks    *Hooks  `json:"hooks,omitempty" mapstructure:"hooks" validate:"omitempty"`                                         // Commands run for this game
+	Server   *Server `json:"server,omitempty" mapstructure:"server" validate:"omitempty"`                                       // Game server restarted when mods change
+	Backup   *Backup `json:"backup,omitempty" mapstructure:"backup" validate:"omitempty"`                                       // Snapshots of the path taken before mods are installed
+}
+
+// InstallOptions returns the options to install mods of the app
+func (a *App) InstallOptions() path.Options {
+	return path.Options{
+		Mode:     path.Mode(a.Install),
+		Symlinks: path.SymlinkPolicy(a.Symlinks),
+	}
 }
 
 func (a *App) String() string {
@@ -178,6 +365,56 @@ func (a *App) String() string {
 }
 
 type Mod struct {
-	Name       string `json:"name,omitempty" mapstructure:"name"`       // Name of the mod
-	WorkshopID string `json:"id" mapstructure:"id" validate:"required"` // Steam Workshop ID
+	Name       string `json:"name,omitempty" mapstructure:"name"`               // Name of the mod
+	WorkshopID string `json:"id" mapstructure:"id" validate:"required,steamid"` // Steam Workshop ID
+}
+
+type Notification struct {
+	Type     string            `json:"type" mapstructure:"type" validate:"required,oneof=webhook discord slack"`          // webhook, discord or slack
+	URL      string            `json:"url" mapstructure:"url" validate:"required,url"`                                    // Webhook URL
+	On       string            `json:"on,omitempty" mapstructure:"on" validate:"omitempty,oneof=always changes failures"` // When to notify, default is changes
+	Template string            `json:"template,omitempty" mapstructure:"template"`                                        // Go template of the JSON body of a webhook
+	Headers  map[string]string `json:"headers,omitempty" mapstructure:"headers"`                                          // Additional HTTP headers of a webhook
+}
+
+type Hooks struct {
+	PreDownload []*Hook `json:"pre_download,omitempty" mapstructure:"pre_download" validate:"omitempty,dive,required"` // Run before steamcmd is started
+	PostCopy    []*Hook `json:"post_copy,omitempty" mapstructure:"post_copy" validate:"omitempty,dive,required"`       // Run after each mod is copied
+	PostRun     []*Hook `json:"post_run,omitempty" mapstructure:"post_run" validate:"omitempty,dive,required"`         // Run after the download run
+}
+
+type Hook struct {
+	Command   string        `json:"command,omitempty" mapstructure:"command" validate:"required_without=RCON"`                   // Shell command to run
+	RCON      string        `json:"rcon,omitempty" mapstructure:"rcon" validate:"required_without=Command"`                      // RCON command sent to the server of the app, a template of the hook environment e.g. servermsg "{{.MOD_NAME}} updated"
+	OnFailure string        `json:"on_failure,omitempty" mapstructure:"on_failure" validate:"omitempty,oneof=abort warn ignore"` // abort (default), warn or ignore
+	Timeout   time.Duration `json:"timeout,omitempty" mapstructure:"timeout"`                                                    // Maximum runtime e.g. 1m, default is no limit
+}
+
+// Server controls a game server that is stopped while its mods are updated.
+// It is started and stopped with systemd or shell commands, RCON can warn players and stop the server.
+type Server struct {
+	Systemd      string        `json:"systemd,omitempty" mapstructure:"systemd"`                // systemd unit of the server
+	Start        string        `json:"start,omitempty" mapstructure:"start"`                    // Shell command to start the server
+	Stop         string        `json:"stop,omitempty" mapstructure:"stop"`                      // Shell command to stop the server
+	Status       string        `json:"status,omitempty" mapstructure:"status"`                  // Shell command that succeeds if the server is running
+	RCON         *RCON         `json:"rcon,omitempty" mapstructure:"rcon" validate:"omitempty"` // RCON endpoint of the server
+	Warning      string        `json:"warning,omitempty" mapstructure:"warning"`                // Message broadcast via RCON before the server is stopped
+	WarningDelay time.Duration `json:"warning_delay,omitempty" mapstructure:"warning_delay"`    // Time between the warning and the stop e.g. 5m
+	StopTimeout  time.Duration `json:"stop_timeout,omitempty" mapstructure:"stop_timeout"`      // Maximum time to wait for the server to stop, default is 1m
+}
+
+// Backup takes a snapshot of the path of an app before updated mods are installed.
+// Snapshots beyond Keep or older than MaxAge are removed after a new snapshot was taken.
+type Backup struct {
+	Dir    string        `json:"dir" mapstructure:"dir" validate:"required"`                                                // Directory of the snapshots
+	Format string        `json:"format,omitempty" mapstructure:"format" validate:"omitempty,oneof=tar.zst tar.gz hardlink"` // tar.zst (default) or tar.gz archive or hardlink snapshot folder
+	Keep   int           `json:"keep,omitempty" mapstructure:"keep" validate:"omitempty,min=1"`                             // Number of snapshots kept, default is 5
+	MaxAge time.Duration `json:"max_age,omitempty" mapstructure:"max_age"`                                                  // Snapshots older than this are removed e.g. 720h, default is no limit
+}
+
+type RCON struct {
+	Address   string `json:"address" mapstructure:"address" validate:"required,hostname_port"` // Address of the RCON endpoint e.g. 127.0.0.1:27015
+	Password  string `json:"password,omitempty" mapstructure:"password"`                       // RCON password
+	Broadcast string `json:"broadcast,omitempty" mapstructure:"broadcast"`                     // Command template to broadcast a message e.g. servermsg "{{.Message}}"
+	Stop      string `json:"stop,omitempty" mapstructure:"stop"`                               // Command to stop the server e.g. quit
 }
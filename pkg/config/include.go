@@ -0,0 +1,413 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IncludeKey is the key of the files included by a config file, globs relative to the including file
+const IncludeKey = "include"
+
+var IncludeLoopErr = errors.New("config file is included twice")
+
+// Position is a line of a config file
+type Position struct {
+	File string
+	Line int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// Sources are the positions of the settings of a config by their path e.g. apps[0].mods[1].id
+type Sources map[string]Position
+
+// Lookup returns the position of the setting with the validator namespace e.g. Config.apps[0].path,
+// or the position of its closest parent if the setting itself is not in a file
+func (s Sources) Lookup(namespace string) (Position, bool) {
+	// the namespace starts with the name of the struct
+	_, p, ok := strings.Cut(namespace, ".")
+	if !ok {
+		return Position{}, false
+	}
+	for p != "" {
+		if pos, ok := s[p]; ok {
+			return pos, true
+		}
+		p = p[:strings.LastIndexAny(p, ".[")+1]
+		p = strings.TrimRight(p, ".[")
+	}
+	return Position{}, false
+}
+
+// Files are the settings of a config file and the files it includes
+type Files struct {
+	Settings   map[string]any // merged settings of all files
+	Sources    Sources        // positions of the settings
+	Changes    []*Change      // migrations applied in memory, config migrate writes them to the files
+	Deprecated []*Deprecation // deprecated settings of files that have the latest version
+}
+
+// Read reads the YAML or JSON config file and the files it includes with their positions.
+// Files of older versions are migrated, included files without a version have the version of the config file.
+// Included files are merged in the order of their globs:
+// maps like steam are merged key by key, apps are merged by their id and their mods appended,
+// other lists are appended and different values of the same setting are an error naming both files.
+func Read(file string) (*Files, error) {
+	l := newLoader(false)
+	if err := l.read(file, true); err != nil {
+		return nil, err
+	}
+	l.files.Settings[VersionKey] = Version
+	return l.files, nil
+}
+
+// Migrate migrates the config file and the files it includes to the latest version and writes the changed files.
+// The config file gets the version even if nothing else changed.
+func Migrate(file string) ([]*Change, error) {
+	l := newLoader(true)
+	if err := l.read(file, true); err != nil {
+		return nil, err
+	}
+	return l.files.Changes, nil
+}
+
+// loader merges config files into settings
+type loader struct {
+	files   *Files
+	sources Sources
+	visited map[string]bool
+	version int  // version of the config file
+	write   bool // write migrated files
+}
+
+func newLoader(write bool) *loader {
+	l := &loader{files: &Files{Settings: map[string]any{}, Sources: Sources{}}, visited: map[string]bool{}, write: write}
+	l.sources = l.files.Sources
+	return l
+}
+
+// read merges the file and the files it includes, main is true for the config file
+func (l *loader) read(file string, main bool) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if l.visited[abs] {
+		return fmt.Errorf("%w: %s", IncludeLoopErr, file)
+	}
+	l.visited[abs] = true
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if err := l.migrate(doc, file, main); err != nil {
+		return err
+	}
+
+	local := Sources{}
+	v, err := decode(doc, "", file, local)
+	if err != nil {
+		return err
+	}
+	settings, ok := v.(map[string]any)
+	if v != nil && !ok {
+		return fmt.Errorf("%s: a config file must be a map of settings", file)
+	}
+	l.files.Deprecated = append(l.files.Deprecated, deprecations(settings, local)...)
+
+	includes, err := patterns(settings[IncludeKey], local[IncludeKey])
+	if err != nil {
+		return err
+	}
+	delete(settings, IncludeKey)
+	delete(settings, VersionKey)
+	if err := l.mergeMap(l.files.Settings, settings, "", "", local); err != nil {
+		return err
+	}
+
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid include %s: %w", local[IncludeKey], pattern, err)
+		}
+		if len(files) == 0 && !strings.ContainsAny(pattern, `*?[\`) {
+			return fmt.Errorf("%s: included file %s does not exist", local[IncludeKey], pattern)
+		}
+		for _, f := range files {
+			if err := l.read(f, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrate migrates the document of a file to the latest version and writes it if the loader writes
+func (l *loader) migrate(doc *yaml.Node, file string, main bool) error {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	v, err := version(root, file)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v == 0 && main:
+		v = 1
+	case v == 0:
+		v = l.version
+	}
+	if main {
+		l.version = v
+	}
+
+	// config migrate also replaces deprecated settings of files that have the latest version,
+	// migrations do not change settings that are already migrated
+	from := v
+	if l.write && v <= Version {
+		from = 1
+	}
+	changes, err := migrate(root, file, from)
+	if err != nil {
+		return err
+	}
+	l.files.Changes = append(l.files.Changes, changes...)
+	if !l.write || (len(changes) == 0 && (!main || value(root, VersionKey) != nil && v == Version)) {
+		return nil
+	}
+	if main || value(root, VersionKey) != nil {
+		setVersion(root)
+	}
+	return writeFile(file, doc)
+}
+
+// writeFile replaces file with the document, JSON files stay JSON
+func writeFile(file string, doc *yaml.Node) error {
+	var b []byte
+	if filepath.Ext(file) == ".json" {
+		v, err := decode(doc, "", file, Sources{})
+		if err != nil {
+			return err
+		}
+		if b, err = json.MarshalIndent(v, "", "  "); err != nil {
+			return err
+		}
+		b = append(b, '\n')
+	} else {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Chmod(info.Mode().Perm())
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// patterns returns the globs of an include setting, a single glob or a list of them
+func patterns(v any, pos Position) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		var globs []string
+		for _, g := range v {
+			s, ok := g.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s must be a list of file globs", pos, IncludeKey)
+			}
+			globs = append(globs, s)
+		}
+		return globs, nil
+	}
+	return nil, fmt.Errorf("%s: %s must be a list of file globs", pos, IncludeKey)
+}
+
+// decode returns the value of a node and records the positions of its settings in sources.
+// Keys are lower case like the keys of viper.
+func decode(n *yaml.Node, path, file string, sources Sources) (any, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return decode(n.Content[0], path, file, sources)
+	case yaml.AliasNode:
+		return decode(n.Alias, path, file, sources)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := strings.ToLower(n.Content[i].Value)
+			p := join(path, key)
+			sources[p] = Position{File: file, Line: n.Content[i].Line}
+			v, err := decode(n.Content[i+1], p, file, sources)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		l := make([]any, 0, len(n.Content))
+		for i, c := range n.Content {
+			p := index(path, i)
+			sources[p] = Position{File: file, Line: c.Line}
+			v, err := decode(c, p, file, sources)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+	}
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", file, n.Line, err)
+	}
+	return v, nil
+}
+
+// mergeMap merges src at srcPath of a file with the positions local into dst at dstPath, see Read
+func (l *loader) mergeMap(dst, src map[string]any, dstPath, srcPath string, local Sources) error {
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := src[k]
+		d, s := join(dstPath, k), join(srcPath, k)
+		cur, ok := dst[k]
+		if !ok || cur == nil {
+			dst[k] = v
+			l.move(local, s, d)
+			continue
+		}
+		switch v := v.(type) {
+		case nil:
+		case map[string]any:
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return l.conflict(d, local, s)
+			}
+			if err := l.mergeMap(m, v, d, s, local); err != nil {
+				return err
+			}
+		case []any:
+			list, ok := cur.([]any)
+			if !ok {
+				return l.conflict(d, local, s)
+			}
+			if d == "apps" {
+				merged, err := l.mergeApps(list, v, local)
+				if err != nil {
+					return err
+				}
+				dst[k] = merged
+				continue
+			}
+			for i := range v {
+				l.move(local, index(s, i), index(d, len(list)+i))
+			}
+			dst[k] = append(list, v...)
+		default:
+			if fmt.Sprint(cur) != fmt.Sprint(v) {
+				return l.conflict(d, local, s)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeApps merges the apps of src into dst by their id, apps that are not in dst are added
+func (l *loader) mergeApps(dst, src []any, local Sources) ([]any, error) {
+	for i, a := range src {
+		app, ok := a.(map[string]any)
+		k := -1
+		if ok {
+			k = indexOfApp(dst, app["id"])
+		}
+		if k < 0 {
+			l.move(local, index("apps", i), index("apps", len(dst)))
+			dst = append(dst, a)
+			continue
+		}
+		if err := l.mergeMap(dst[k].(map[string]any), app, index("apps", k), index("apps", i), local); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// move records the positions of the setting at from and of its children at to
+func (l *loader) move(local Sources, from, to string) {
+	for p, pos := range local {
+		if p == from || strings.HasPrefix(p, from+".") || strings.HasPrefix(p, from+"[") {
+			l.sources[to+strings.TrimPrefix(p, from)] = pos
+		}
+	}
+}
+
+// conflict returns the error of a setting with different values in two files
+func (l *loader) conflict(path string, local Sources, srcPath string) error {
+	return fmt.Errorf("%s has different values in %s and %s", path, l.sources[path], local[srcPath])
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func index(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
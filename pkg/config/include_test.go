@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files with their content to a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadIncludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		path    string // setting to compare
+		want    any
+		wantErr string // part of the error
+	}{
+		{
+			name: "maps are merged key by key",
+			files: map[string]string{
+				"config.yaml": "steam:\n  cmd: /usr/games/steamcmd\ninclude: login.yaml\n",
+				"login.yaml":  "steam:\n  login:\n    username: anonymous\n",
+			},
+			path: "steam",
+			want: map[string]any{"cmd": "/usr/games/steamcmd", "login": map[string]any{"username": "anonymous"}},
+		},
+		{
+			name: "apps are merged by id and their mods appended",
+			files: map[string]string{
+				"config.yaml":    "apps:\n  - id: 108600\n    path: /srv/pz\n    mods:\n      - id: \"111\"\ninclude: apps/*.yaml\n",
+				"apps/pz.yaml":   "apps:\n  - id: \"108600\"\n    mods:\n      - id: \"222\"\n",
+				"apps/rust.yaml": "apps:\n  - id: \"252490\"\n    path: /srv/rust\n",
+			},
+			path: "apps",
+			want: []any{
+				map[string]any{"id": 108600, "path": "/srv/pz", "mods": []any{map[string]any{"id": "111"}, map[string]any{"id": "222"}}},
+				map[string]any{"id": "252490", "path": "/srv/rust"},
+			},
+		},
+		{
+			name: "other lists are appended in the order of the globs",
+			files: map[string]string{
+				"config.yaml": "notifications:\n  - url: http://a\ninclude: [c.yaml, b.yaml]\n",
+				"b.yaml":      "notifications:\n  - url: http://b\n",
+				"c.yaml":      "notifications:\n  - url: http://c\n",
+			},
+			path: "notifications",
+			want: []any{map[string]any{"url": "http://a"}, map[string]any{"url": "http://c"}, map[string]any{"url": "http://b"}},
+		},
+		{
+			name: "same values do not conflict",
+			files: map[string]string{
+				"config.yaml": "state: /var/lib/swd.json\ninclude: other.yaml\n",
+				"other.yaml":  "state: /var/lib/swd.json\n",
+			},
+			path: "state",
+			want: "/var/lib/swd.json",
+		},
+		{
+			name: "glob without files",
+			files: map[string]string{
+				"config.yaml": "state: x\ninclude: conf.d/*.yaml\n",
+			},
+			path: "state",
+			want: "x",
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"config.yaml": "include: missing.yaml\n",
+			},
+			wantErr: "missing.yaml does not exist",
+		},
+		{
+			name: "include is not a list of globs",
+			files: map[string]string{
+				"config.yaml": "include:\n  file: other.yaml\n",
+			},
+			wantErr: "include must be a list of file globs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			files, err := Read(filepath.Join(dir, "config.yaml"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if _, ok := files.Settings[IncludeKey]; ok {
+				t.Errorf("Read() settings have %s", IncludeKey)
+			}
+			got, _ := lookup(files.Settings, tt.path)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() %s = %#v, want %#v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadIncludeConflictNamesBothFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "state: /var/lib/swd.json\ninclude: other.yaml\n",
+		"other.yaml":  "\nstate: /tmp/swd.json\n",
+	})
+	_, err := Read(filepath.Join(dir, "config.yaml"))
+	want := "state has different values in " + filepath.Join(dir, "config.yaml") + ":1 and " + filepath.Join(dir, "other.yaml") + ":2"
+	if err == nil || err.Error() != want {
+		t.Errorf("Read() error = %v, want %s", err, want)
+	}
+}
+
+func TestReadIncludeLoop(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name:  "itself",
+			files: map[string]string{"config.yaml": "include: config.yaml\n"},
+		},
+		{
+			name: "each other",
+			files: map[string]string{
+				"config.yaml":   "include: conf.d/a.yaml\n",
+				"conf.d/a.yaml": "include: b.yaml\n",
+				"conf.d/b.yaml": "include: ../config.yaml\n",
+			},
+		},
+		{
+			name: "twice",
+			files: map[string]string{
+				"config.yaml": "include: [a.yaml, '*.yaml']\n",
+				"a.yaml":      "state: x\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			if _, err := Read(filepath.Join(dir, "config.yaml")); !errors.Is(err, IncludeLoopErr) {
+				t.Errorf("Read() error = %v, want %v", err, IncludeLoopErr)
+			}
+		})
+	}
+}
+
+func TestSourcesLookup(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `steam:
+  cmd: /usr/games/steamcmd
+apps:
+  - id: "108600"
+    mods:
+      - id: "111"
+include: pz.yaml
+`,
+		"pz.yaml": `apps:
+  - id: "108600"
+    path: /srv/pz
+    mods:
+      - id: "222"
+  - id: "252490"
+`,
+	})
+	files, err := Read(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	config, pz := filepath.Join(dir, "config.yaml"), filepath.Join(dir, "pz.yaml")
+
+	tests := []struct {
+		namespace string
+		want      Position
+		wantOK    bool
+	}{
+		{namespace: "Config.steam.cmd", want: Position{File: config, Line: 2}, wantOK: true},
+		{namespace: "Config.apps[0].id", want: Position{File: config, Line: 4}, wantOK: true},
+		{namespace: "Config.apps[0].mods[0].id", want: Position{File: config, Line: 6}, wantOK: true},
+		{namespace: "Config.apps[0].mods[1].id", want: Position{File: pz, Line: 5}, wantOK: true},
+		{namespace: "Config.apps[0].path", want: Position{File: pz, Line: 3}, wantOK: true},
+		{namespace: "Config.apps[1].id", want: Position{File: pz, Line: 6}, wantOK: true},
+		// settings that are not in a file have the position of their closest parent
+		{namespace: "Config.apps[1].path", want: Position{File: pz, Line: 6}, wantOK: true},
+		{namespace: "Config.steam.login.username", want: Position{File: config, Line: 1}, wantOK: true},
+		{namespace: "Config.state"},
+		{namespace: "Config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			got, ok := files.Sources.Lookup(tt.namespace)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Lookup() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
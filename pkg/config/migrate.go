@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+)
+
+// Version is the version of the config format
+const Version = 1
+
+// VersionKey is the key of the version in the settings of a config file, files without it have version 1
+const VersionKey = "version"
+
+var UnsupportedVersionErr = errors.New("unsupported config version, update steam-workshop-downloader")
+
+// Change is a change of a migration to a config file
+type Change struct {
+	File        string // config file
+	Version     int    // version the file was migrated to
+	Description string // what changed
+}
+
+func (c *Change) String() string {
+	return fmt.Sprintf("%s: version %d: %s", c.File, c.Version, c.Description)
+}
+
+// Deprecation is a setting that was replaced in a version
+type Deprecation struct {
+	Path     string   // setting e.g. steam.cache.url
+	Version  int      // version that replaced the setting
+	Use      string   // what to use instead
+	Position Position // position of the setting
+}
+
+func (d *Deprecation) Error() string {
+	return fmt.Sprintf("%s is deprecated since version %d, use %s or run config migrate", d.Path, d.Version, d.Use)
+}
+
+// migration migrates the root of a config file from the version before to its version
+type migration struct {
+	version    int
+	migrate    func(root *yaml.Node) []string // returns the changes
+	deprecated []deprecated                   // settings replaced by the migration
+}
+
+// deprecated is a setting replaced by a migration, is returns true for deprecated values, nil for all values
+type deprecated struct {
+	path string
+	use  string
+	is   func(v any) bool
+}
+
+// migrations of the config format by version, a change of the format adds a migration and increments Version.
+// Version 1 is the first format and has none.
+var migrations []migration
+
+// migrate migrates the root of a config file and its profiles from version to the latest version
+func migrate(root *yaml.Node, file string, version int) ([]*Change, error) {
+	if version > Version {
+		return nil, fmt.Errorf("%w: %s has version %d, the latest is %d", UnsupportedVersionErr, file, version, Version)
+	}
+	prefixes := []string{""}
+	targets := []*yaml.Node{root}
+	if profiles := value(root, ProfilesKey); profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(profiles.Content); i += 2 {
+			prefixes = append(prefixes, ProfilesKey+"."+profiles.Content[i].Value+": ")
+			targets = append(targets, profiles.Content[i+1])
+		}
+	}
+
+	var changes []*Change
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		for i, target := range targets {
+			for _, c := range m.migrate(target) {
+				changes = append(changes, &Change{File: file, Version: m.version, Description: prefixes[i] + c})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// deprecations returns the deprecated settings of a config file and its profiles
+func deprecations(settings map[string]any, sources Sources) []*Deprecation {
+	prefixes := []string{""}
+	targets := []map[string]any{settings}
+	if profiles, ok := settings[ProfilesKey].(map[string]any); ok {
+		for _, name := range ProfileNames(mapsOf(profiles)) {
+			prefixes = append(prefixes, ProfilesKey+"."+name+".")
+			targets = append(targets, profiles[name].(map[string]any))
+		}
+	}
+
+	var found []*Deprecation
+	for _, m := range migrations {
+		for _, d := range m.deprecated {
+			for i, target := range targets {
+				v, ok := lookup(target, d.path)
+				if !ok || (d.is != nil && !d.is(v)) {
+					continue
+				}
+				path := prefixes[i] + d.path
+				found = append(found, &Deprecation{Path: path, Version: m.version, Use: d.use, Position: sources[path]})
+			}
+		}
+	}
+	return found
+}
+
+// mapsOf returns the values of m that are maps
+func mapsOf(m map[string]any) map[string]map[string]any {
+	maps := map[string]map[string]any{}
+	for k, v := range m {
+		if v, ok := v.(map[string]any); ok {
+			maps[k] = v
+		}
+	}
+	return maps
+}
+
+// version returns the version of the root of a config file, 0 if it has none
+func version(root *yaml.Node, file string) (int, error) {
+	v := value(root, VersionKey)
+	if v == nil {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v.Value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s:%d: %s must be a positive number", file, v.Line, VersionKey)
+	}
+	return n, nil
+}
+
+// setVersion sets the version of the root of a config file to the latest version
+func setVersion(root *yaml.Node) {
+	if v := value(root, VersionKey); v != nil {
+		v.Value = strconv.Itoa(Version)
+		v.Tag = "!!int"
+		return
+	}
+	root.Content = append([]*yaml.Node{scalar(VersionKey, 0), {Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(Version)}}, root.Content...)
+}
+
+// lookup returns the value of a setting by its path of map keys
+func lookup(settings map[string]any, path string) (any, bool) {
+	var v any = settings
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// value returns the value of the key of a mapping node, keys are compared in lower case
+func value(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.ToLower(m.Content[i].Value) == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalar(value string, line int) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Line: line}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// v1 is a config file of version 1 without a version, its keys are not sorted and it has comments
+const v1 = `# downloads the mods of the server
+steam:
+  # anonymous is enough for most workshops
+  login:
+    username: anonymous
+  cmd: /usr/games/steamcmd
+  source: cache
+  cache:
+    url: http://cache:8081
+    token: secret # shared with cache serve
+apps:
+  - id: "108600"
+    path: /srv/pz/mods
+    mods:
+      - id: "2392709985" # first mod
+include: mods/*.yaml
+state: /var/lib/swd/state.json
+`
+
+// v1Mods is a file included by v1
+const v1Mods = `apps:
+  # more mods of the same app
+  - id: "108600"
+    mods:
+      - id: "2487022075"
+`
+
+// writeV1 writes the v1 config with its included file to a temporary directory and returns the config file
+func writeV1(t *testing.T, config string) string {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"config.yaml": config, "mods/pz.yaml": v1Mods})
+	return filepath.Join(dir, "config.yaml")
+}
+
+func readFile(t *testing.T, file string) string {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    string // config file after the migration
+		wantErr error
+	}{
+		{
+			name:   "unversioned file only gets the version",
+			config: v1,
+			want:   "version: 1\n" + v1,
+		},
+		{
+			name:   "current version is kept",
+			config: "version: 1\n" + v1,
+			want:   "version: 1\n" + v1,
+		},
+		{
+			name:    "newer version",
+			config:  "version: 2\n" + v1,
+			want:    "version: 2\n" + v1,
+			wantErr: UnsupportedVersionErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeV1(t, tt.config)
+			changes, err := Migrate(file)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Migrate() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(changes) != 0 {
+				t.Errorf("Migrate() = %v, want no changes", changes)
+			}
+			if got := readFile(t, file); got != tt.want {
+				t.Errorf("config file = %q, want %q", got, tt.want)
+			}
+			if got := readFile(t, filepath.Join(filepath.Dir(file), "mods", "pz.yaml")); got != v1Mods {
+				t.Errorf("included file = %q, want %q", got, v1Mods)
+			}
+		})
+	}
+}
+
+func TestReadVersion1(t *testing.T) {
+	file := writeV1(t, v1)
+	files, err := Read(file)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(files.Changes) != 0 || len(files.Deprecated) != 0 {
+		t.Errorf("Read() changes = %v, deprecated = %v, want none", files.Changes, files.Deprecated)
+	}
+	if got := files.Settings[VersionKey]; got != Version {
+		t.Errorf("Read() version = %v, want %v", got, Version)
+	}
+	cache, _ := lookup(files.Settings, "steam.cache")
+	if c, ok := cache.(map[string]any); !ok || c["url"] != "http://cache:8081" || c["token"] != "secret" {
+		t.Errorf("Read() steam.cache = %v, want the url and token", cache)
+	}
+	if got := readFile(t, file); got != v1 {
+		t.Errorf("Read() changed the config file to %q", got)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{name: "none", config: "state: x\n", want: 0},
+		{name: "number", config: "version: 1\n", want: 1},
+		{name: "newer", config: "version: 3\n", want: 3},
+		{name: "zero", config: "version: 0\n", wantErr: true},
+		{name: "text", config: "version: one\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &yaml.Node{}
+			if err := yaml.Unmarshal([]byte(tt.config), doc); err != nil {
+				t.Fatal(err)
+			}
+			got, err := version(doc.Content[0], "config.yaml")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("version() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("version() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProfilesKey is the key of the profiles in the settings of a config file
+const ProfilesKey = "profiles"
+
+// ExtendsKey is the key of the profile a profile is based on, profiles without it are based on the config itself
+const ExtendsKey = "extends"
+
+var (
+	ProfileNotFoundErr = errors.New("profile not found")
+	ProfileLoopErr     = errors.New("profiles extend each other")
+)
+
+// Profiles returns the profiles of the settings of a config file by name
+func Profiles(settings map[string]any) (map[string]map[string]any, error) {
+	profiles := map[string]map[string]any{}
+	raw, ok := settings[ProfilesKey]
+	if !ok || raw == nil {
+		return profiles, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a map of profiles by name", ProfilesKey)
+	}
+	for name, p := range m {
+		switch p := p.(type) {
+		case map[string]any:
+			profiles[name] = p
+		case nil:
+			profiles[name] = map[string]any{}
+		default:
+			return nil, fmt.Errorf("profile %s must be a map of settings", name)
+		}
+	}
+	return profiles, nil
+}
+
+// ReadProfiles reads the profiles from the files <name>.yaml, <name>.yml or <name>.json in dir.
+// A missing dir has no profiles.
+func ReadProfiles(dir string) (map[string]map[string]any, error) {
+	profiles := map[string]map[string]any{}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if _, ok := profiles[name]; ok {
+			return nil, fmt.Errorf("profile %s is defined twice in %s", name, dir)
+		}
+		file := filepath.Join(dir, entry.Name())
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		p, err := readProfile(file, b)
+		if err != nil {
+			return nil, err
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// readProfile returns the settings of a profile file migrated to the latest version in memory
+func readProfile(file string, b []byte) (map[string]any, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return map[string]any{}, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("profile %s must be a map of settings", file)
+	}
+	v, err := version(root, file)
+	if err != nil {
+		return nil, err
+	}
+	if v == 0 {
+		v = 1
+	}
+	if _, err := migrate(root, file, v); err != nil {
+		return nil, err
+	}
+	p, err := decode(doc, "", file, Sources{})
+	if err != nil {
+		return nil, err
+	}
+	settings := p.(map[string]any)
+	delete(settings, VersionKey)
+	return settings, nil
+}
+
+// ProfileNames returns the sorted names of the profiles
+func ProfileNames(profiles map[string]map[string]any) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile returns the settings of a config file with the named profile merged over them.
+// A profile is merged over the profile it extends, or over the config itself.
+// Maps like steam are merged key by key, apps are merged by their id so a profile can
+// change the path of an app or replace its mods, other values like lists are replaced.
+func ApplyProfile(settings map[string]any, profiles map[string]map[string]any, name string) (map[string]any, error) {
+	// the profile chain from the profile to the config
+	var chain []map[string]any
+	seen := map[string]bool{}
+	for n := name; n != ""; {
+		if seen[n] {
+			return nil, fmt.Errorf("%w: %s", ProfileLoopErr, n)
+		}
+		seen[n] = true
+		p, ok := profiles[n]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ProfileNotFoundErr, n)
+		}
+		chain = append(chain, p)
+		extends, ok := p[ExtendsKey].(string)
+		if _, set := p[ExtendsKey]; set && !ok {
+			return nil, fmt.Errorf("%s of profile %s must be the name of a profile", ExtendsKey, n)
+		}
+		n = extends
+	}
+
+	merged := merge(nil, settings)
+	delete(merged, ProfilesKey)
+	for i := len(chain) - 1; i >= 0; i-- {
+		merged = merge(merged, chain[i])
+	}
+	delete(merged, ExtendsKey)
+	return merged, nil
+}
+
+// merge returns a copy of dst with the settings of src, see ApplyProfile
+func merge(dst, src map[string]any) map[string]any {
+	merged := make(map[string]any, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+	for k, v := range src {
+		switch v := v.(type) {
+		case map[string]any:
+			d, _ := merged[k].(map[string]any)
+			merged[k] = merge(d, v)
+		case []any:
+			if d, ok := merged[k].([]any); ok && k == "apps" {
+				merged[k] = mergeApps(d, v)
+			} else {
+				merged[k] = v
+			}
+		default:
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// mergeApps merges the apps of src into dst by their id, apps that are not in dst are added
+func mergeApps(dst, src []any) []any {
+	merged := make([]any, len(dst))
+	copy(merged, dst)
+	for _, s := range src {
+		app, ok := s.(map[string]any)
+		if !ok {
+			merged = append(merged, s)
+			continue
+		}
+		i := indexOfApp(merged, app["id"])
+		if i < 0 {
+			merged = append(merged, app)
+			continue
+		}
+		d, _ := merged[i].(map[string]any)
+		merged[i] = merge(d, app)
+	}
+	return merged
+}
+
+// indexOfApp returns the index of the app with the id in apps, -1 if there is none.
+// Ids are compared as strings, YAML reads unquoted ids as numbers.
+func indexOfApp(apps []any, id any) int {
+	if id == nil {
+		return -1
+	}
+	for i, a := range apps {
+		if app, ok := a.(map[string]any); ok && fmt.Sprint(app["id"]) == fmt.Sprint(id) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// profilesConfig is a config file with profiles extending each other
+const profilesConfig = `steam:
+  cmd: /usr/games/steamcmd
+  login:
+    username: anonymous
+apps:
+  - id: "108600"
+    path: /srv/pz
+    mods:
+      - id: "111"
+      - id: "222"
+  - id: "252490"
+    path: /srv/rust
+notifications:
+  - url: http://prod
+profiles:
+  staging:
+    steam:
+      login:
+        username: staging
+    apps:
+      - id: 108600
+        path: /srv/pz-staging
+    notifications:
+      - url: http://staging
+  testing:
+    extends: staging
+    apps:
+      - id: "108600"
+        mods:
+          - id: "333"
+      - id: "346110"
+        path: /srv/ark
+  empty:
+  loop-a:
+    extends: loop-b
+  loop-b:
+    extends: loop-a
+  orphan:
+    extends: missing
+  invalid:
+    extends: [staging]
+`
+
+func readProfilesConfig(t *testing.T) (map[string]any, map[string]map[string]any) {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"config.yaml": profilesConfig})
+	files, err := Read(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	profiles, err := Profiles(files.Settings)
+	if err != nil {
+		t.Fatalf("Profiles() error = %v", err)
+	}
+	return files.Settings, profiles
+}
+
+func TestApplyProfile(t *testing.T) {
+	settings, profiles := readProfilesConfig(t)
+	tests := []struct {
+		profile string
+		path    string // setting to compare
+		want    any
+		wantErr error
+	}{
+		// maps are merged key by key
+		{profile: "staging", path: "steam.login.username", want: "staging"},
+		{profile: "staging", path: "steam.cmd", want: "/usr/games/steamcmd"},
+		// lists other than apps are replaced
+		{profile: "staging", path: "notifications", want: []any{map[string]any{"url": "http://staging"}}},
+		// apps are merged by id, ids are compared as strings and the id of the profile is kept
+		{profile: "staging", path: "apps", want: []any{
+			map[string]any{"id": 108600, "path": "/srv/pz-staging", "mods": []any{map[string]any{"id": "111"}, map[string]any{"id": "222"}}},
+			map[string]any{"id": "252490", "path": "/srv/rust"},
+		}},
+		// a profile is merged over the profile it extends and replaces the mods of an app
+		{profile: "testing", path: "apps", want: []any{
+			map[string]any{"id": "108600", "path": "/srv/pz-staging", "mods": []any{map[string]any{"id": "333"}}},
+			map[string]any{"id": "252490", "path": "/srv/rust"},
+			map[string]any{"id": "346110", "path": "/srv/ark"},
+		}},
+		{profile: "testing", path: "steam.login.username", want: "staging"},
+		{profile: "empty", path: "steam.login.username", want: "anonymous"},
+		{profile: "loop-a", wantErr: ProfileLoopErr},
+		{profile: "orphan", wantErr: ProfileNotFoundErr},
+		{profile: "unknown", wantErr: ProfileNotFoundErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile+" "+tt.path, func(t *testing.T) {
+			got, err := ApplyProfile(settings, profiles, tt.profile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyProfile() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			for _, key := range []string{ProfilesKey, ExtendsKey} {
+				if _, ok := got[key]; ok {
+					t.Errorf("ApplyProfile() settings have %s", key)
+				}
+			}
+			if v, _ := lookup(got, tt.path); !reflect.DeepEqual(v, tt.want) {
+				t.Errorf("ApplyProfile() %s = %#v, want %#v", tt.path, v, tt.want)
+			}
+		})
+	}
+
+	if _, err := ApplyProfile(settings, profiles, "invalid"); err == nil {
+		t.Error("ApplyProfile() of a profile extending a list, want an error")
+	}
+	// the settings of the config are not changed by a profile
+	if v, _ := lookup(settings, "apps"); len(v.([]any)) != 2 {
+		t.Errorf("ApplyProfile() changed the apps of the config to %v", v)
+	}
+	if v, _ := lookup(settings, "steam.login.username"); v != "anonymous" {
+		t.Errorf("ApplyProfile() changed the username of the config to %v", v)
+	}
+}
+
+func TestReadProfiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"staging.yaml":  "extends: base\nstate: /tmp/state.json\n",
+		"base.json":     `{"steam": {"cmd": "/opt/steamcmd"}}`,
+		"empty.yml":     "",
+		"notes.txt":     "not a profile",
+		"old/prod.yaml": "state: /var/lib/swd.json\n",
+	})
+	profiles, err := ReadProfiles(dir)
+	if err != nil {
+		t.Fatalf("ReadProfiles() error = %v", err)
+	}
+	if got, want := ProfileNames(profiles), []string{"base", "empty", "staging"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadProfiles() = %v, want %v", got, want)
+	}
+	merged, err := ApplyProfile(map[string]any{"state": "/var/lib/swd.json"}, profiles, "staging")
+	if err != nil {
+		t.Fatalf("ApplyProfile() error = %v", err)
+	}
+	want := map[string]any{"state": "/tmp/state.json", "steam": map[string]any{"cmd": "/opt/steamcmd"}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("ApplyProfile() = %v, want %v", merged, want)
+	}
+
+	if profiles, err := ReadProfiles(filepath.Join(dir, "missing")); err != nil || len(profiles) != 0 {
+		t.Errorf("ReadProfiles() of a missing dir = %v, %v, want no profiles", profiles, err)
+	}
+	twice := writeFiles(t, map[string]string{"prod.yaml": "state: a\n", "prod.json": `{"state": "b"}`})
+	if _, err := ReadProfiles(twice); err == nil {
+		t.Error("ReadProfiles() of a profile defined twice, want an error")
+	}
+}
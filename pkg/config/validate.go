@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/go-playground/validator/v10"
+	"os"
+)
+
+// WorkshopItem is what the workshop knows about a mod, it is used by the online validation
+type WorkshopItem struct {
+	Exists bool   // the item exists in the workshop
+	AppID  string // Steam App ID the item belongs to, empty if unknown
+}
+
+type workshopKey struct{}
+
+type writableKey struct{}
+
+// WithWorkshop returns a context that lets ValidateContext check the mods against the workshop items by workshop id
+func WithWorkshop(ctx context.Context, items map[string]WorkshopItem) context.Context {
+	return context.WithValue(ctx, workshopKey{}, items)
+}
+
+// validateID checks that a Steam App or Workshop ID is a number
+func validateID(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithWritable returns a context that lets ValidateContext check that directories are writable by creating a file in them
+func WithWritable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writableKey{}, true)
+}
+
+// validateWritable checks that a directory, or its nearest existing parent if it does not exist yet, is a directory.
+// With WithWritable in ctx, it also checks that files can be created in it.
+func validateWritable(ctx context.Context, fl validator.FieldLevel) bool {
+	existing, err := path.Existing(fl.Field().String())
+	if err != nil {
+		return false
+	}
+	if info, err := os.Stat(existing); err != nil || !info.IsDir() {
+		return false
+	}
+	if probe, _ := ctx.Value(writableKey{}).(bool); !probe {
+		return true
+	}
+	f, err := os.CreateTemp(existing, ".swd-writable-*")
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return true
+}
+
+// validateConfig checks the apps against each other: duplicate app ids, duplicate mods of an app and
+// overlapping paths. With workshop items in ctx, mods also have to exist and belong to their app.
+func validateConfig(ctx context.Context, sl validator.StructLevel) {
+	c := sl.Current().Interface().(Config)
+	items, online := ctx.Value(workshopKey{}).(map[string]WorkshopItem)
+
+	apps := map[string]int{}
+	for i, app := range c.Apps {
+		if app == nil {
+			continue
+		}
+		field := fmt.Sprintf("apps[%d]", i)
+		if j, ok := apps[app.AppID]; ok {
+			sl.ReportError(app.AppID, field+".id", "AppID", "duplicate", fmt.Sprintf("apps[%d].id", j))
+		} else if app.AppID != "" {
+			apps[app.AppID] = i
+		}
+
+		for j, other := range c.Apps[:i] {
+			if other == nil || app.Path == "" || other.Path == "" {
+				continue
+			}
+			if path.Within(other.Path, app.Path) || path.Within(app.Path, other.Path) {
+				sl.ReportError(app.Path, field+".path", "Path", "overlap", fmt.Sprintf("apps[%d].path", j))
+				break
+			}
+		}
+
+		mods := map[string]int{}
+		for k, mod := range app.Mods {
+			if mod == nil {
+				continue
+			}
+			modField := fmt.Sprintf("%s.mods[%d].id", field, k)
+			if j, ok := mods[mod.WorkshopID]; ok {
+				sl.ReportError(mod.WorkshopID, modField, "WorkshopID", "duplicate", fmt.Sprintf("%s.mods[%d].id", field, j))
+				continue
+			}
+			mods[mod.WorkshopID] = k
+
+			if !online {
+				continue
+			}
+			switch item, ok := items[mod.WorkshopID]; {
+			case !ok || !item.Exists:
+				sl.ReportError(mod.WorkshopID, modField, "WorkshopID", "workshop", "")
+			case item.AppID != "" && item.AppID != app.AppID:
+				sl.ReportError(mod.WorkshopID, modField, "WorkshopID", "workshop_app", item.AppID)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/runner"
+	logger "github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Daemon checks the workshop for updates on an interval and downloads updated mods
+type Daemon struct {
+	mu       sync.Mutex
+	cfg      *config.Config
+	runner   *runner.Runner
+	interval time.Duration
+	trigger  chan struct{}
+}
+
+func NewDaemon(cfg *config.Config, r *runner.Runner, interval time.Duration) *Daemon {
+	return &Daemon{
+		cfg:      cfg,
+		runner:   r,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Config returns the current config
+func (d *Daemon) Config() *config.Config {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cfg
+}
+
+// SetConfig replaces the config and triggers a check for updates
+func (d *Daemon) SetConfig(cfg *config.Config) {
+	d.mu.Lock()
+	d.cfg = cfg
+	d.mu.Unlock()
+	d.Trigger()
+}
+
+// Trigger starts a check for updates without waiting for the interval
+func (d *Daemon) Trigger() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+		// a check is already pending
+	}
+}
+
+// Run checks for updates until ctx is done, the first check starts immediately
+func (d *Daemon) Run(ctx context.Context) {
+	for {
+		d.check(ctx)
+
+		next := time.Now().Add(d.interval)
+		d.runner.SetNextRun(next)
+		logger.WithField("next", next.Format(time.RFC3339)).Info("waiting for next check")
+
+		timer := time.NewTimer(d.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.trigger:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// check downloads all mods that were updated since they were installed
+func (d *Daemon) check(ctx context.Context) {
+	logger.Info("checking for updates")
+	summary, err := d.runner.Run(ctx, d.Config(), runner.Options{OnlyUpdated: true})
+	switch {
+	case errors.Is(err, runner.BusyErr):
+		logger.Warn("skipping check, a download is already running")
+	case err != nil:
+		logger.WithError(err).Error("failed to update mods")
+	case summary.Items > 0:
+		logger.WithFields(logger.Fields{
+			"copied": summary.Copied,
+			"failed": summary.Failed,
+		}).Info("updated mods")
+	}
+}
+
+// Handler returns an HTTP handler serving the status of the daemon as JSON on /status
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		_ = e.Encode(d.runner.Status())
+	})
+	return mux
+}
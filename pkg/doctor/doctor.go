@@ -0,0 +1,190 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Status is the result of a check
+type Status string
+
+const (
+	OK   Status = "ok"   // nothing to do
+	Warn Status = "warn" // may fail later
+	Fail Status = "fail" // downloads fail
+)
+
+// MinFree is the free space of a volume below which it is reported
+const MinFree = 1 << 30
+
+// Prompt is the prompt of steamcmd waiting for commands
+const Prompt = "Steam>"
+
+// Check is the result of a diagnostic, checks that did not pass have a fix
+type Check struct {
+	Name   string `json:"name"`             // what was checked
+	Status Status `json:"status"`           // result
+	Detail string `json:"detail,omitempty"` // what was found
+	Fix    string `json:"fix,omitempty"`    // what to do if it did not pass
+}
+
+// Failed returns true if any of the checks failed
+func Failed(checks []*Check) bool {
+	for _, c := range checks {
+		if c.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// SteamCMD checks that steamcmd exists, is executable and reaches its prompt within timeout
+func SteamCMD(ctx context.Context, cmd string, timeout time.Duration) []*Check {
+	info, err := os.Stat(cmd)
+	switch {
+	case err != nil:
+		return []*Check{{Name: "steamcmd", Status: Fail, Detail: err.Error(),
+			Fix: "install steamcmd or set steam.cmd to its path"}}
+	case info.IsDir():
+		return []*Check{{Name: "steamcmd", Status: Fail, Detail: cmd + " is a directory",
+			Fix: "set steam.cmd to the steamcmd executable in " + cmd}}
+	case runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0:
+		return []*Check{{Name: "steamcmd", Status: Fail, Detail: cmd + " is not executable",
+			Fix: "run chmod +x " + cmd}}
+	}
+	return []*Check{{Name: "steamcmd", Status: OK, Detail: cmd}, prompt(ctx, cmd, timeout)}
+}
+
+// prompt starts steamcmd, waits for its prompt and quits it
+func prompt(ctx context.Context, cmd string, timeout time.Duration) *Check {
+	check := &Check{Name: "steamcmd prompt"}
+	manually := fmt.Sprintf("run %s manually to see why it does not start", cmd)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, cmd)
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		check.Status, check.Detail, check.Fix = Fail, err.Error(), manually
+		return check
+	}
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		check.Status, check.Detail, check.Fix = Fail, err.Error(), manually
+		return check
+	}
+	start := time.Now()
+	if err := c.Start(); err != nil {
+		check.Status, check.Detail, check.Fix = Fail, err.Error(), manually
+		return check
+	}
+
+	found := make(chan bool, 1)
+	done := make(chan struct{})
+	var last string
+	go func() {
+		defer close(done)
+		ok, tail := waitFor(stdout, Prompt)
+		last = tail
+		found <- ok
+		_, _ = io.Copy(io.Discard, stdout)
+	}()
+
+	select {
+	case ok := <-found:
+		if ok {
+			_, _ = io.WriteString(stdin, "quit\n")
+			check.Status, check.Detail = OK, fmt.Sprintf("reached the prompt in %s", time.Since(start).Round(time.Millisecond))
+		} else {
+			check.Status, check.Fix = Fail, manually
+			check.Detail = "exited before reaching the prompt"
+			if last != "" {
+				check.Detail += ": " + last
+			}
+		}
+		_ = stdin.Close()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+		check.Status = Fail
+		check.Detail = fmt.Sprintf("did not reach the prompt within %s", timeout)
+		check.Fix = "steamcmd may be updating itself, run it manually once or raise --timeout"
+	}
+	_ = c.Wait()
+	return check
+}
+
+// waitFor reads r until s was read or r ends, it returns whether s was found and the last line read
+func waitFor(r io.Reader, s string) (bool, string) {
+	var buf []byte
+	chunk := make([]byte, 4096)
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if bytes.Contains(buf, []byte(s)) {
+			return true, ""
+		}
+		// keep the end of the output for the prompt split across reads and the last line
+		if len(buf) > 4096 {
+			buf = buf[len(buf)-4096:]
+		}
+		if err != nil {
+			lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+			return false, strings.TrimSpace(lines[len(lines)-1])
+		}
+	}
+}
+
+// Writable checks that files can be created in dir, a dir that does not exist yet needs a writable parent
+func Writable(name, dir, fix string) *Check {
+	check := &Check{Name: name}
+	existing, err := path.Existing(dir)
+	if err != nil {
+		check.Status, check.Detail, check.Fix = Fail, err.Error(), fix
+		return check
+	}
+	f, err := os.CreateTemp(existing, ".swd-doctor-*")
+	if err != nil {
+		check.Status, check.Detail, check.Fix = Fail, fmt.Sprintf("%s is not writable: %v", existing, err), fix
+		return check
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+
+	check.Status, check.Detail = OK, dir
+	if existing != dir {
+		check.Detail = fmt.Sprintf("%s does not exist yet, %s is writable", dir, existing)
+	}
+	return check
+}
+
+// DiskSpace checks that the volume of dir has at least MinFree bytes available
+func DiskSpace(name, dir string) *Check {
+	check := &Check{Name: name}
+	free, err := path.Free(dir)
+	switch {
+	case errors.Is(err, path.NotSupportedErr):
+		check.Status, check.Detail = Warn, "free space is unknown on "+runtime.GOOS
+	case err != nil:
+		check.Status, check.Detail, check.Fix = Fail, err.Error(), "check that "+dir+" exists"
+	case free < MinFree:
+		check.Status = Warn
+		check.Detail = fmt.Sprintf("%s free on the volume of %s", output.Bytes(free), dir)
+		check.Fix = fmt.Sprintf("free up space on the volume of %s, at least %s", dir, output.Bytes(MinFree))
+	default:
+		check.Status, check.Detail = OK, fmt.Sprintf("%s free on the volume of %s", output.Bytes(free), dir)
+	}
+	return check
+}
@@ -0,0 +1,68 @@
+// Package event describes what happens during a download run.
+//
+// Events are emitted by the downloader and can be written as JSON lines with
+// `download --output jsonl`. The JSON schema of an event is stable: fields are
+// only added, never renamed or removed, and unset fields are omitted.
+//
+//	type         string  always set, one of the Type constants below
+//	time         string  always set, RFC 3339 timestamp with nanoseconds
+//	schema       number  schema version, set for run_started
+//	items        number  number of mods in the run, set for run_started
+//	user         string  steam user, set for login
+//	app_id       string  Steam App ID of the mod, set for item_* events
+//	workshop_id  string  Steam Workshop ID of the mod, set for item_* events
+//	name         string  configured name of the mod, set for item_* events
+//	path         string  download folder for item_downloaded, destination for item_copying and item_copied
+//	done         number  bytes downloaded or copied so far
+//	total        number  total bytes to download or copy
+//	error        string  reason of a failure, set for failed logins, item_failed and failed runs
+//	summary      object  summary of the run, set for run_finished, see Summary
+package event
+
+import "time"
+
+// SchemaVersion is the version of the JSON schema of events
+const SchemaVersion = 1
+
+// Type is the kind of event emitted during a download run
+type Type string
+
+const (
+	RunStarted     Type = "run_started"     // steamcmd is about to be started
+	Login          Type = "login"           // steamcmd logged in to steam
+	ItemStarted    Type = "item_started"    // steamcmd started downloading an item
+	ItemProgress   Type = "item_progress"   // download progress of the current item
+	ItemDownloaded Type = "item_downloaded" // steamcmd finished downloading an item
+	ItemCopying    Type = "item_copying"    // copy progress of an item to its destination
+	ItemCopied     Type = "item_copied"     // item was copied to its destination
+	ItemFailed     Type = "item_failed"     // item failed to download or copy
+	RunFinished    Type = "run_finished"    // steamcmd exited and all items were handled
+)
+
+// Event is emitted while a download run is in progress
+type Event struct {
+	Type       Type      `json:"type" yaml:"type"`                                   // kind of the event
+	Time       time.Time `json:"time" yaml:"time"`                                   // time the event was emitted
+	Schema     int       `json:"schema,omitempty" yaml:"schema,omitempty"`           // schema version, set for RunStarted
+	Items      int       `json:"items,omitempty" yaml:"items,omitempty"`             // number of items in the run, set for RunStarted
+	User       string    `json:"user,omitempty" yaml:"user,omitempty"`               // steam user, set for Login
+	AppID      string    `json:"app_id,omitempty" yaml:"app_id,omitempty"`           // Steam App ID of the item
+	WorkshopID string    `json:"workshop_id,omitempty" yaml:"workshop_id,omitempty"` // Steam Workshop ID of the item
+	Name       string    `json:"name,omitempty" yaml:"name,omitempty"`               // configured name of the item
+	Path       string    `json:"path,omitempty" yaml:"path,omitempty"`               // download folder or destination of the item
+	Done       int64     `json:"done,omitempty" yaml:"done,omitempty"`               // bytes downloaded or copied so far
+	Total      int64     `json:"total,omitempty" yaml:"total,omitempty"`             // total bytes to download or copy
+	Error      string    `json:"error,omitempty" yaml:"error,omitempty"`             // error message of a failure
+	Summary    *Summary  `json:"summary,omitempty" yaml:"summary,omitempty"`         // summary of the run, set for RunFinished
+}
+
+// Percent returns the progress of the event in percent
+func (e *Event) Percent() float64 {
+	if e == nil || e.Total <= 0 {
+		return 0
+	}
+	return float64(e.Done) / float64(e.Total) * 100
+}
+
+// Handler receives events
+type Handler func(e Event)
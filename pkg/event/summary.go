@@ -0,0 +1,121 @@
+package event
+
+import "time"
+
+// Status is the result of a single item in a run
+type Status string
+
+const (
+	Downloaded Status = "downloaded" // downloaded by steamcmd but not copied
+	Copied     Status = "copied"     // downloaded and copied to the destination
+	Failed     Status = "failed"     // failed to download or copy
+)
+
+// Result is the result of a single item in a run
+type Result struct {
+	AppID      string `json:"app_id" yaml:"app_id"`                   // Steam App ID of the item
+	WorkshopID string `json:"workshop_id" yaml:"workshop_id"`         // Steam Workshop ID of the item
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`   // configured name of the item
+	Title      string `json:"title,omitempty" yaml:"title,omitempty"` // workshop title of the item
+	Updated    bool   `json:"updated" yaml:"updated"`                 // a new version of the item was installed
+	Status     Status `json:"status" yaml:"status"`                   // result of the item
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`   // destination of the item
+	Bytes      int64  `json:"bytes,omitempty" yaml:"bytes,omitempty"` // bytes copied to the destination
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"` // reason of a failure
+}
+
+// Summary summarizes a run, it is built from the events of the run
+//
+//	items             number  number of mods in the run
+//	downloaded        number  number of mods downloaded by steamcmd
+//	copied            number  number of mods copied to their destination
+//	updated           number  number of mods installed in a new version
+//	failed            number  number of mods that failed
+//	bytes             number  bytes copied to all destinations
+//	started           string  RFC 3339 timestamp of the start of the run
+//	finished          string  RFC 3339 timestamp of the end of the run
+//	duration_seconds  number  duration of the run in seconds
+//	error             string  reason why the run failed, e.g. steamcmd exit status
+//	exit_code         number  exit code of steamcmd, -1 if it did not exit by itself
+//	results           array   result per mod with app_id, workshop_id, name, title, updated, status, path, bytes and error
+type Summary struct {
+	Items           int       `json:"items" yaml:"items"`
+	Downloaded      int       `json:"downloaded" yaml:"downloaded"`
+	Copied          int       `json:"copied" yaml:"copied"`
+	Updated         int       `json:"updated" yaml:"updated"`
+	Failed          int       `json:"failed" yaml:"failed"`
+	Bytes           int64     `json:"bytes" yaml:"bytes"`
+	Started         time.Time `json:"started" yaml:"started"`
+	Finished        time.Time `json:"finished" yaml:"finished"`
+	DurationSeconds float64   `json:"duration_seconds" yaml:"duration_seconds"`
+	Error           string    `json:"error,omitempty" yaml:"error,omitempty"`
+	ExitCode        int       `json:"exit_code" yaml:"exit_code"`
+	Results         []*Result `json:"results" yaml:"results"`
+}
+
+// Handle updates the summary with the given event, it implements Handler
+func (s *Summary) Handle(e Event) {
+	switch e.Type {
+	case RunStarted:
+		s.Items = e.Items
+		s.Started = e.Time
+	case ItemDownloaded:
+		r := s.result(e)
+		r.Status = Downloaded
+		s.Downloaded++
+	case ItemCopying:
+		s.result(e).Bytes = e.Done
+	case ItemCopied:
+		r := s.result(e)
+		r.Status = Copied
+		r.Path = e.Path
+		s.Bytes += r.Bytes
+		s.Copied++
+	case ItemFailed:
+		r := s.result(e)
+		r.Status = Failed
+		r.Path = e.Path
+		r.Error = e.Error
+		s.Failed++
+	case RunFinished:
+		s.Finished = e.Time
+		s.DurationSeconds = e.Time.Sub(s.Started).Seconds()
+		s.Error = e.Error
+	}
+}
+
+// Result returns the result of the given workshop id, nil if it is not part of the summary
+func (s *Summary) Result(workshopID string) *Result {
+	for _, r := range s.Results {
+		if r.WorkshopID == workshopID {
+			return r
+		}
+	}
+	return nil
+}
+
+// result returns the result of the item of the event and creates it if necessary
+func (s *Summary) result(e Event) *Result {
+	if r := s.Result(e.WorkshopID); r != nil {
+		return r
+	}
+	r := &Result{AppID: e.AppID, WorkshopID: e.WorkshopID, Name: e.Name}
+	s.Results = append(s.Results, r)
+	return r
+}
+
+// Success returns true if the run finished without errors
+func (s *Summary) Success() bool {
+	return s != nil && s.Error == "" && s.Failed == 0
+}
+
+// Filter returns the results for which keep returns true
+func (s *Summary) Filter(keep func(r *Result) bool) []*Result {
+	var results []*Result
+	for _, r := range s.Results {
+		if keep(r) {
+			results = append(results, r)
+		}
+	}
+	return results
+}
@@ -0,0 +1,226 @@
+package gameserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/hooks"
+	"github.com/Cehir/steam-workshop-downloader/pkg/rcon"
+	logger "github.com/sirupsen/logrus"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultStopTimeout = time.Minute      // default time to wait for the server to stop
+	pollInterval       = 2 * time.Second  // interval to check if the server stopped
+	rconTimeout        = 10 * time.Second // timeout of rcon connections
+	commandTimeout     = 5 * time.Minute  // timeout of the stop and start commands of a restart
+)
+
+var (
+	NoStopErr  = errors.New("server has no stop command, systemd unit or rcon stop command")
+	NoStartErr = errors.New("server has no start command or systemd unit")
+)
+
+// Controller starts and stops the game server of an app
+type Controller struct {
+	app *config.App
+	cfg *config.Server
+}
+
+// NewController returns a controller for the server of app, the app needs a server config
+func NewController(app *config.App) (*Controller, error) {
+	cfg := app.Server
+	if cfg == nil {
+		return nil, fmt.Errorf("app %s has no server", app.AppID)
+	}
+	if cfg.Stop == "" && cfg.Systemd == "" && (cfg.RCON == nil || cfg.RCON.Stop == "") {
+		return nil, NoStopErr
+	}
+	if cfg.Start == "" && cfg.Systemd == "" {
+		return nil, NoStartErr
+	}
+	return &Controller{app: app, cfg: cfg}, nil
+}
+
+func (c *Controller) String() string {
+	return c.app.String()
+}
+
+// env returns the environment of the shell commands
+func (c *Controller) env() hooks.Env {
+	return hooks.Env{
+		"APP_ID":      c.app.AppID,
+		"APP_NAME":    c.app.Name,
+		"DESTINATION": c.app.Path,
+	}
+}
+
+// Running returns true if the server is running.
+// Without status command or systemd unit the server is assumed to be running.
+func (c *Controller) Running(ctx context.Context) bool {
+	switch {
+	case c.cfg.Status != "":
+		return hooks.Exec(ctx, c.cfg.Status, c.env()) == nil
+	case c.cfg.Systemd != "":
+		return hooks.Exec(ctx, "systemctl is-active --quiet "+c.cfg.Systemd, c.env()) == nil
+	default:
+		return true
+	}
+}
+
+// Warn broadcasts the warning via RCON and waits for the warning delay
+func (c *Controller) Warn(ctx context.Context) error {
+	if c.cfg.Warning == "" || c.cfg.RCON == nil || c.cfg.RCON.Broadcast == "" {
+		return nil
+	}
+
+	tmpl, err := template.New("broadcast").Parse(c.cfg.RCON.Broadcast)
+	if err != nil {
+		return fmt.Errorf("failed to parse broadcast command: %w", err)
+	}
+	var command bytes.Buffer
+	if err := tmpl.Execute(&command, map[string]string{"Message": c.cfg.Warning}); err != nil {
+		return fmt.Errorf("failed to execute broadcast command: %w", err)
+	}
+
+	if _, err := c.exec(command.String()); err != nil {
+		return err
+	}
+	logger.WithField("app", c.app.String()).WithField("delay", c.cfg.WarningDelay).Info("warned players")
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.cfg.WarningDelay):
+		return nil
+	}
+}
+
+// Stop stops the server and waits until it stopped if its status can be checked with the status command,
+// systemd or RCON, a server that stopped no longer accepts RCON connections
+func (c *Controller) Stop(ctx context.Context) error {
+	logger.WithField("app", c.app.String()).Info("stopping server")
+
+	var err error
+	switch {
+	case c.cfg.Stop != "":
+		err = hooks.Exec(ctx, c.cfg.Stop, c.env())
+	case c.cfg.Systemd != "":
+		err = hooks.Exec(ctx, "systemctl stop "+c.cfg.Systemd, c.env())
+	default:
+		// the server closes the connection when it stops
+		if _, err = c.exec(c.cfg.RCON.Stop); errors.Is(err, rcon.ClosedErr) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stop server: %w", err)
+	}
+
+	running := c.Running
+	if c.cfg.Status == "" && c.cfg.Systemd == "" {
+		if c.cfg.RCON == nil {
+			return nil
+		}
+		running = c.reachable
+	}
+
+	timeout := c.stopTimeout()
+	deadline := time.Now().Add(timeout)
+	for running(ctx) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server did not stop within %s", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	return nil
+}
+
+// stopTimeout returns the time to wait for the server to stop
+func (c *Controller) stopTimeout() time.Duration {
+	if c.cfg.StopTimeout <= 0 {
+		return defaultStopTimeout
+	}
+	return c.cfg.StopTimeout
+}
+
+// reachable returns true if the server accepts RCON connections, also with a wrong password
+func (c *Controller) reachable(ctx context.Context) bool {
+	client, err := rcon.Dial(c.cfg.RCON.Address, c.cfg.RCON.Password, rconTimeout)
+	if err != nil {
+		return errors.Is(err, rcon.AuthErr)
+	}
+	_ = client.Close()
+	return true
+}
+
+// Start starts the server
+func (c *Controller) Start(ctx context.Context) error {
+	logger.WithField("app", c.app.String()).Info("starting server")
+
+	var err error
+	if c.cfg.Start != "" {
+		err = hooks.Exec(ctx, c.cfg.Start, c.env())
+	} else {
+		err = hooks.Exec(ctx, "systemctl start "+c.cfg.Systemd, c.env())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
+
+// Restart stops a running server, calls install and starts the server again.
+// A server that is not running is not started, the server is started even if install failed.
+// Once the server is stopped, the restart is finished even if ctx is cancelled so the server does not stay down.
+func (c *Controller) Restart(ctx context.Context, install func() error) error {
+	// the status command fails on a cancelled context, the server would look stopped
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !c.Running(ctx) {
+		logger.WithField("app", c.app.String()).Info("server is not running, installing without restart")
+		return install()
+	}
+
+	if err := c.Warn(ctx); err != nil {
+		logger.WithError(err).WithField("app", c.app.String()).Warn("failed to warn players")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), c.stopTimeout()+commandTimeout)
+	defer cancel()
+	if err := c.Stop(stopCtx); err != nil {
+		return err
+	}
+
+	installErr := install()
+	startCtx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	if err := c.Start(startCtx); err != nil {
+		return err
+	}
+	return installErr
+}
+
+// exec executes a command via RCON
+func (c *Controller) exec(command string) (string, error) {
+	client, err := rcon.Dial(c.cfg.RCON.Address, c.cfg.RCON.Password, rconTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	return client.Exec(command)
+}
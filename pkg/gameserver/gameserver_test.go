@@ -0,0 +1,113 @@
+package gameserver
+
+import (
+	"context"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer returns an app with a server controlled by shell commands on a file marking it running,
+// the stop command removes the file after a delay and the start command recreates it
+func fakeServer(t *testing.T) (*config.App, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake server uses a POSIX shell")
+	}
+	dir := t.TempDir()
+	running := filepath.Join(dir, "running")
+	if err := os.WriteFile(running, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &config.App{
+		AppID: "108600",
+		Path:  dir,
+		Server: &config.Server{
+			Status:      "test -f " + running,
+			Stop:        "(sleep 0.5; rm -f " + running + ") >/dev/null 2>&1 &",
+			Start:       "touch " + running,
+			StopTimeout: 10 * time.Second,
+		},
+	}, running
+}
+
+func TestRestartCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		// cancel cancels the context of the restart at some point
+		cancel func(cancel context.CancelFunc) func() error
+	}{
+		{
+			name: "while waiting for the server to stop",
+			cancel: func(cancel context.CancelFunc) func() error {
+				time.AfterFunc(100*time.Millisecond, cancel)
+				return func() error { return nil }
+			},
+		},
+		{
+			name: "while installing",
+			cancel: func(cancel context.CancelFunc) func() error {
+				return func() error {
+					cancel()
+					return nil
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, running := fakeServer(t)
+			c, err := NewController(app)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			install := tt.cancel(cancel)
+			installed := false
+			err = c.Restart(ctx, func() error {
+				if _, err := os.Stat(running); err == nil {
+					t.Error("install was called before the server stopped")
+				}
+				installed = true
+				return install()
+			})
+			if err != nil {
+				t.Fatalf("Restart() error = %v", err)
+			}
+			if !installed {
+				t.Error("Restart() did not install")
+			}
+			if _, err := os.Stat(running); err != nil {
+				t.Error("Restart() did not start the server again")
+			}
+		})
+	}
+}
+
+// a run cancelled before the server is stopped leaves it running
+func TestRestartCancelledBeforeStop(t *testing.T) {
+	app, running := fakeServer(t)
+	c, err := NewController(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = c.Restart(ctx, func() error {
+		t.Error("install was called")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("Restart() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := os.Stat(running); err != nil {
+		t.Error("Restart() stopped the server")
+	}
+}
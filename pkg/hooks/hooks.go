@@ -0,0 +1,180 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/rcon"
+	logger "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Stage is the point of a download run at which hooks are run
+type Stage string
+
+const (
+	PreDownload Stage = "pre_download" // before steamcmd is started
+	PostCopy    Stage = "post_copy"    // after each mod is copied
+	PostRun     Stage = "post_run"     // after the download run
+)
+
+// failure policies of a hook
+const (
+	Abort  = "abort"  // stop the run, this is the default
+	Warn   = "warn"   // log a warning and continue
+	Ignore = "ignore" // continue silently
+)
+
+// rconTimeout is the timeout of RCON hooks without a timeout
+const rconTimeout = 10 * time.Second
+
+var (
+	NoRCONErr = errors.New("rcon hooks need an app with a server rcon endpoint")
+)
+
+// Env is the context passed to hooks as environment variables, e.g. SWD_APP_ID
+type Env map[string]string
+
+// environ returns the environment of the current process extended by e
+func (e Env) environ() []string {
+	env := os.Environ()
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("SWD_%s=%s", k, e[k]))
+	}
+	return env
+}
+
+// Of returns the hooks of the stage, h may be nil
+func Of(h *config.Hooks, stage Stage) []*config.Hook {
+	if h == nil {
+		return nil
+	}
+	switch stage {
+	case PreDownload:
+		return h.PreDownload
+	case PostCopy:
+		return h.PostCopy
+	case PostRun:
+		return h.PostRun
+	default:
+		return nil
+	}
+}
+
+// Run runs the hooks one after another with env, RCON hooks are sent to endpoint which may be nil.
+// It returns an error for the first failed hook with the abort policy, other failures are logged.
+func Run(ctx context.Context, stage Stage, hooks []*config.Hook, env Env, endpoint *config.RCON) error {
+	for _, hook := range hooks {
+		err := run(ctx, stage, hook, env, endpoint)
+		if err == nil {
+			continue
+		}
+
+		switch hook.OnFailure {
+		case Ignore:
+			logger.WithError(err).WithField("command", command(hook)).Debug("hook failed")
+		case Warn:
+			logger.WithError(err).WithField("command", command(hook)).Warn("hook failed")
+		default:
+			return fmt.Errorf("%s hook %q failed: %w", stage, command(hook), err)
+		}
+	}
+	return nil
+}
+
+// command returns the shell or RCON command of the hook
+func command(hook *config.Hook) string {
+	if hook.Command != "" {
+		return hook.Command
+	}
+	return hook.RCON
+}
+
+// run runs a single hook
+func run(ctx context.Context, stage Stage, hook *config.Hook, env Env, endpoint *config.RCON) error {
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	logger.WithField("stage", stage).WithField("command", command(hook)).Info("running hook")
+	env["HOOK"] = string(stage)
+	if hook.Command == "" {
+		return execRCON(ctx, endpoint, hook.RCON, env)
+	}
+	return Exec(ctx, hook.Command, env)
+}
+
+// execRCON sends the command, a template of env, to the RCON endpoint
+func execRCON(ctx context.Context, endpoint *config.RCON, command string, env Env) error {
+	if endpoint == nil {
+		return NoRCONErr
+	}
+
+	tmpl, err := template.New("rcon").Option("missingkey=zero").Parse(command)
+	if err != nil {
+		return fmt.Errorf("failed to parse rcon command: %w", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, map[string]string(env)); err != nil {
+		return fmt.Errorf("failed to execute rcon command: %w", err)
+	}
+
+	timeout := rconTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	client, err := rcon.Dial(endpoint.Address, endpoint.Password, timeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	response, err := client.Exec(b.String())
+	if s := strings.TrimSpace(response); s != "" {
+		logger.WithField("command", b.String()).Debug(s)
+	}
+	return err
+}
+
+// Exec runs a shell command with env, its output is logged.
+// The error contains the last line of the output if the command failed.
+func Exec(ctx context.Context, command string, env Env) error {
+	name, args := shell(command)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env.environ()
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	if s := strings.TrimSpace(out.String()); s != "" {
+		logger.WithField("command", command).Debug(s)
+	}
+	if err != nil && out.Len() > 0 {
+		return fmt.Errorf("%w: %s", err, lastLine(out.String()))
+	}
+	return err
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package hooks
+
+// shell returns the shell and its arguments to run a command
+func shell(command string) (string, []string) {
+	return "/bin/sh", []string{"-c", command}
+}
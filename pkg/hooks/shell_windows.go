@@ -0,0 +1,6 @@
+package hooks
+
+// shell returns the shell and its arguments to run a command
+func shell(command string) (string, []string) {
+	return "cmd.exe", []string{"/C", command}
+}
@@ -0,0 +1,83 @@
+// Package manifest reads the workshop manifests of steamcmd, steamapps/workshop/appworkshop_<appid>.acf
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/vdf"
+	"os"
+)
+
+// Manifest is the workshop manifest of an app
+type Manifest struct {
+	AppID string           // Steam App ID
+	Items map[string]*Item // installed items by workshop id
+}
+
+// Item is a workshop item installed by steamcmd
+type Item struct {
+	WorkshopID  string // Steam Workshop ID
+	Size        int64  // size on disk in bytes
+	TimeUpdated int64  // workshop unix time of the installed version
+	Manifest    string // depot manifest id of the installed version
+
+	LatestTimeUpdated int64 // workshop unix time of the latest version known to steamcmd
+}
+
+// Get returns the item with the workshop id, nil if it is not installed
+func (m *Manifest) Get(workshopID string) *Item {
+	if m == nil {
+		return nil
+	}
+	return m.Items[workshopID]
+}
+
+// Load reads the manifest at path, a missing file results in nil without error
+func Load(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workshop manifest: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	root, err := vdf.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workshop manifest %s: %w", path, err)
+	}
+
+	workshop := root.Get("AppWorkshop")
+	if workshop == nil {
+		return nil, fmt.Errorf("workshop manifest %s has no AppWorkshop block", path)
+	}
+	m := &Manifest{
+		AppID: workshop.String("appid"),
+		Items: map[string]*Item{},
+	}
+	for _, kv := range children(workshop.Get("WorkshopItemsInstalled")) {
+		m.Items[kv.Key] = &Item{
+			WorkshopID:  kv.Key,
+			Size:        kv.Int("size"),
+			TimeUpdated: kv.Int("timeupdated"),
+			Manifest:    kv.String("manifest"),
+		}
+	}
+	for _, kv := range children(workshop.Get("WorkshopItemDetails")) {
+		if item, ok := m.Items[kv.Key]; ok {
+			item.LatestTimeUpdated = kv.Int("latest_timeupdated")
+		}
+	}
+	return m, nil
+}
+
+// children returns the children of a block, nil if kv is nil
+func children(kv *vdf.KeyValue) []*vdf.KeyValue {
+	if kv == nil {
+		return nil
+	}
+	return kv.Children
+}
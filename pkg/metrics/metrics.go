@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// namespace is the prefix of all metric names
+const namespace = "swd"
+
+// mod identifies a mod of an app
+type mod struct {
+	appID      string
+	workshopID string
+}
+
+// Metrics records download runs and writes them in the Prometheus text format
+type Metrics struct {
+	mu          sync.Mutex
+	runs        map[string]float64 // finished runs by result
+	duration    float64            // duration of the last run in seconds
+	lastRun     float64            // unix time of the end of the last run
+	exitCode    float64            // steamcmd exit code of the last run
+	downloaded  map[string]float64 // downloaded items by app id
+	failed      map[string]float64 // failed items by app id
+	bytes       map[string]float64 // bytes copied by app id
+	lastSuccess map[mod]float64    // unix time of the last successful install by mod
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		runs:        make(map[string]float64),
+		downloaded:  make(map[string]float64),
+		failed:      make(map[string]float64),
+		bytes:       make(map[string]float64),
+		lastSuccess: make(map[mod]float64),
+	}
+}
+
+// Load sets the last successful install of every mod recorded in the state
+func (m *Metrics) Load(st *state.State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range st.Items {
+		if item.Installed.IsZero() {
+			continue
+		}
+		m.lastSuccess[mod{item.AppID, item.WorkshopID}] = float64(item.Installed.Unix())
+	}
+}
+
+// Handle records the summary of finished runs, it implements event.Handler
+func (m *Metrics) Handle(e event.Event) {
+	if e.Type == event.RunFinished && e.Summary != nil {
+		m.Record(e.Summary)
+	}
+}
+
+// Record records the summary of a run
+func (m *Metrics) Record(s *event.Summary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := "success"
+	if !s.Success() {
+		result = "failure"
+	}
+	m.runs[result]++
+	m.duration = s.DurationSeconds
+	m.lastRun = float64(s.Finished.Unix())
+	m.exitCode = float64(s.ExitCode)
+
+	for _, r := range s.Results {
+		switch r.Status {
+		case event.Copied:
+			m.downloaded[r.AppID]++
+			m.bytes[r.AppID] += float64(r.Bytes)
+			m.lastSuccess[mod{r.AppID, r.WorkshopID}] = float64(s.Finished.Unix())
+		case event.Downloaded:
+			m.downloaded[r.AppID]++
+		case event.Failed:
+			m.failed[r.AppID]++
+		}
+	}
+}
+
+// WriteTo writes all metrics in the Prometheus text format to w
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+	header(&b, "runs_total", "counter", "Number of finished download runs by result.")
+	for _, result := range sortedKeys(m.runs) {
+		sample(&b, "runs_total", m.runs[result], "result", result)
+	}
+	header(&b, "last_run_duration_seconds", "gauge", "Duration of the last download run.")
+	sample(&b, "last_run_duration_seconds", m.duration)
+	header(&b, "last_run_timestamp_seconds", "gauge", "Unix time the last download run finished.")
+	sample(&b, "last_run_timestamp_seconds", m.lastRun)
+	header(&b, "steamcmd_exit_code", "gauge", "Exit code of steamcmd in the last download run, -1 if it was killed.")
+	sample(&b, "steamcmd_exit_code", m.exitCode)
+
+	header(&b, "items_downloaded_total", "counter", "Number of mods downloaded by app.")
+	for _, app := range sortedKeys(m.downloaded) {
+		sample(&b, "items_downloaded_total", m.downloaded[app], "app_id", app)
+	}
+	header(&b, "items_failed_total", "counter", "Number of mods that failed to download or copy by app.")
+	for _, app := range sortedKeys(m.failed) {
+		sample(&b, "items_failed_total", m.failed[app], "app_id", app)
+	}
+	header(&b, "bytes_copied_total", "counter", "Bytes copied to the destination by app.")
+	for _, app := range sortedKeys(m.bytes) {
+		sample(&b, "bytes_copied_total", m.bytes[app], "app_id", app)
+	}
+
+	header(&b, "mod_last_success_timestamp_seconds", "gauge", "Unix time a mod was last installed successfully.")
+	mods := make([]mod, 0, len(m.lastSuccess))
+	for k := range m.lastSuccess {
+		mods = append(mods, k)
+	}
+	sort.Slice(mods, func(i, j int) bool {
+		if mods[i].appID != mods[j].appID {
+			return mods[i].appID < mods[j].appID
+		}
+		return mods[i].workshopID < mods[j].workshopID
+	})
+	for _, k := range mods {
+		sample(&b, "mod_last_success_timestamp_seconds", m.lastSuccess[k], "app_id", k.appID, "workshop_id", k.workshopID)
+	}
+
+	return b.WriteTo(w)
+}
+
+// Handler returns an HTTP handler serving the metrics
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = m.WriteTo(w)
+	})
+}
+
+// WriteFile writes the metrics to path for the textfile collector of the node exporter.
+// The file is replaced atomically, so the collector never reads a partial file.
+func (m *Metrics) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create metrics file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := m.WriteTo(tmp); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadFile adds the counters of a file written by WriteFile, so runs writing the same file keep counting.
+// A missing file has no counters, the gauges of the last run are replaced by the next run anyway.
+func (m *Metrics) ReadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read metrics file: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters := map[string]map[string]float64{
+		"runs_total":             m.runs,
+		"items_downloaded_total": m.downloaded,
+		"items_failed_total":     m.failed,
+		"bytes_copied_total":     m.bytes,
+	}
+	for i, line := range strings.Split(string(b), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line[:strings.IndexAny(line+" ", "{ ")]
+		counter, ok := counters[strings.TrimPrefix(name, namespace+"_")]
+		if !ok {
+			continue
+		}
+		label, value, err := parseSample(line[len(name):])
+		if err != nil {
+			return fmt.Errorf("failed to read metrics file %s:%d: %w", path, i+1, err)
+		}
+		counter[label] += value
+	}
+	return nil
+}
+
+// parseSample returns the value of the only label and the value of a sample written by sample without its name
+func parseSample(s string) (label string, value float64, err error) {
+	i := strings.LastIndexByte(s, ' ')
+	if i < 0 {
+		return "", 0, errors.New("invalid sample")
+	}
+	labels := s[:i]
+	if value, err = strconv.ParseFloat(s[i+1:], 64); err != nil {
+		return "", 0, err
+	}
+	if labels == "" {
+		return "", value, nil
+	}
+	_, quoted, ok := strings.Cut(labels, "=")
+	if !ok || !strings.HasPrefix(labels, "{") || !strings.HasSuffix(quoted, "}") {
+		return "", 0, errors.New("invalid labels")
+	}
+	if label, err = strconv.Unquote(strings.TrimSuffix(quoted, "}")); err != nil {
+		return "", 0, fmt.Errorf("invalid labels: %w", err)
+	}
+	return label, value, nil
+}
+
+// header writes the help and type line of a metric
+func header(b *bytes.Buffer, name, typ, help string) {
+	_, _ = fmt.Fprintf(b, "# HELP %s_%s %s\n", namespace, name, help)
+	_, _ = fmt.Fprintf(b, "# TYPE %s_%s %s\n", namespace, name, typ)
+}
+
+// sample writes a sample of a metric with label name and value pairs
+func sample(b *bytes.Buffer, name string, value float64, labels ...string) {
+	_, _ = fmt.Fprintf(b, "%s_%s", namespace, name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escape(labels[i+1])))
+		}
+		_, _ = fmt.Fprintf(b, "{%s}", strings.Join(pairs, ","))
+	}
+	_, _ = fmt.Fprintf(b, " %s\n", strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// escape escapes a label value
+func escape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swd.prom")
+	run := &event.Summary{
+		Failed:   1,
+		Finished: time.Unix(1700000000, 0),
+		Results: []*event.Result{
+			{AppID: "108600", WorkshopID: "111", Status: event.Copied, Bytes: 100},
+			{AppID: "108600", WorkshopID: "222", Status: event.Failed},
+			{AppID: `a "quoted" app`, WorkshopID: "333", Status: event.Copied, Bytes: 5},
+		},
+	}
+
+	// every run writes a fresh file like download --metrics-file
+	for i := 0; i < 2; i++ {
+		m := NewMetrics()
+		if err := m.ReadFile(file); err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		m.Record(run)
+		if err := m.WriteFile(file); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`swd_runs_total{result="failure"} 2`,
+		`swd_items_downloaded_total{app_id="108600"} 2`,
+		`swd_items_downloaded_total{app_id="a \"quoted\" app"} 2`,
+		`swd_items_failed_total{app_id="108600"} 2`,
+		`swd_bytes_copied_total{app_id="108600"} 200`,
+		`swd_last_run_timestamp_seconds 1700000000`,
+		`swd_mod_last_success_timestamp_seconds{app_id="108600",workshop_id="111"} 1700000000`,
+	} {
+		if !bytes.Contains(b, []byte(want+"\n")) {
+			t.Errorf("metrics file has no %s:\n%s", want, b)
+		}
+	}
+	if strings.Count(string(b), "swd_runs_total{") != 1 {
+		t.Errorf("metrics file has more than one runs_total sample:\n%s", b)
+	}
+}
+
+func TestReadFileInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "missing file", wantErr: false},
+		{name: "other metrics", content: "node_load1 0.5\nswd_last_run_duration_seconds 3\n", wantErr: false},
+		{name: "invalid value", content: "swd_runs_total{result=\"success\"} many\n", wantErr: true},
+		{name: "invalid labels", content: "swd_runs_total{result=success} 1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "swd.prom")
+			if tt.content != "" {
+				if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := NewMetrics().ReadFile(file); (err != nil) != tt.wantErr {
+				t.Errorf("ReadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
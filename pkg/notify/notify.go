@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	Always   = "always"   // notify after every run
+	Changes  = "changes"  // notify if a mod was updated or failed
+	Failures = "failures" // notify if a mod or the run failed
+)
+
+// maxMessageLength is the maximum length of a chat message, discord allows 2000 characters
+const maxMessageLength = 1900
+
+// Message is the data of a notification, it is available in webhook templates
+type Message struct {
+	Summary *event.Summary  `json:"summary"` // summary of the run
+	Updated []*event.Result `json:"updated"` // mods installed in a new version
+	Failed  []*event.Result `json:"failed"`  // mods that failed to download or copy
+}
+
+// NewMessage returns the message of the summary
+func NewMessage(s *event.Summary) *Message {
+	return &Message{
+		Summary: s,
+		Updated: s.Filter(func(r *event.Result) bool { return r.Updated }),
+		Failed:  s.Filter(func(r *event.Result) bool { return r.Status == event.Failed }),
+	}
+}
+
+// Text returns the message as plain text for chats
+func (m *Message) Text() string {
+	var b strings.Builder
+	if len(m.Updated) > 0 {
+		b.WriteString("**Mods updated**\n")
+		for _, r := range m.Updated {
+			_, _ = fmt.Fprintf(&b, "- %s\n", title(r))
+		}
+	}
+	if len(m.Failed) > 0 {
+		b.WriteString("**Mods failed**\n")
+		for _, r := range m.Failed {
+			_, _ = fmt.Fprintf(&b, "- %s: %s\n", title(r), r.Error)
+		}
+	}
+	if m.Summary.Error != "" {
+		_, _ = fmt.Fprintf(&b, "**Download failed**: %s\n", m.Summary.Error)
+	}
+	if b.Len() == 0 {
+		b.WriteString("All mods are up to date\n")
+	}
+
+	return truncate(b.String(), maxMessageLength)
+}
+
+// truncate shortens text to at most n bytes on a rune boundary and marks the cut with an ellipsis
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n] + "\n..."
+}
+
+// title returns the workshop title, configured name or workshop id of the result
+func title(r *event.Result) string {
+	name := r.Title
+	if name == "" {
+		name = r.Name
+	}
+	if name == "" {
+		return r.WorkshopID
+	}
+	return fmt.Sprintf("%s (%s)", name, r.WorkshopID)
+}
+
+// Notifier sends notifications about a download run
+type Notifier struct {
+	cfg  *config.Notification
+	tmpl *template.Template
+	http *http.Client
+}
+
+func NewNotifier(cfg *config.Notification) (*Notifier, error) {
+	n := &Notifier{
+		cfg:  cfg,
+		http: &http.Client{Timeout: 30 * time.Second},
+	}
+	if cfg.Template != "" {
+		tmpl, err := template.New(cfg.URL).Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template of %s notification: %w", cfg.Type, err)
+		}
+		n.tmpl = tmpl
+	}
+	return n, nil
+}
+
+// Wants returns true if the notification should be sent for the summary
+func (n *Notifier) Wants(s *event.Summary) bool {
+	switch n.cfg.On {
+	case Always:
+		return true
+	case Failures:
+		return !s.Success()
+	default:
+		return s.Updated > 0 || !s.Success()
+	}
+}
+
+// Notify sends the notification for the summary
+func (n *Notifier) Notify(ctx context.Context, s *event.Summary) error {
+	body, err := n.body(NewMessage(s))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s notification: %w", n.cfg.Type, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to send %s notification: %s: %s", n.cfg.Type, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// body returns the request body in the format of the notification type
+func (n *Notifier) body(m *Message) ([]byte, error) {
+	switch n.cfg.Type {
+	case "discord":
+		return json.Marshal(map[string]string{"content": m.Text()})
+	case "slack":
+		// slack uses single asterisks for bold text
+		return json.Marshal(map[string]string{"text": strings.ReplaceAll(m.Text(), "**", "*")})
+	default:
+		if n.tmpl == nil {
+			return json.Marshal(m)
+		}
+		var b bytes.Buffer
+		if err := n.tmpl.Execute(&b, m); err != nil {
+			return nil, fmt.Errorf("failed to execute template: %w", err)
+		}
+		return b.Bytes(), nil
+	}
+}
+
+// toJSON encodes v as JSON for templates, e.g. {"text": {{ json .Summary.Error }}}
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Send sends all notifications that want the summary, errors are returned together
+func Send(ctx context.Context, notifications []*config.Notification, s *event.Summary) error {
+	var errs []string
+	for _, cfg := range notifications {
+		n, err := NewNotifier(cfg)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if !n.Wants(s) {
+			continue
+		}
+		if err := n.Notify(ctx, s); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send notifications: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// summary returns a summary with an updated and a failed mod
+func summary() *event.Summary {
+	return &event.Summary{
+		Items:   2,
+		Updated: 1,
+		Failed:  1,
+		Results: []*event.Result{
+			{AppID: "108600", WorkshopID: "111", Title: "Mod A", Updated: true, Status: event.Copied},
+			{AppID: "108600", WorkshopID: "222", Name: "Mod B", Status: event.Failed, Error: "timeout"},
+		},
+	}
+}
+
+// request is a request received by the test server
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// receive starts a server that records the requests it receives and answers with status
+func receive(t *testing.T, status int) (string, <-chan request) {
+	t.Helper()
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, requests
+}
+
+func TestNotify(t *testing.T) {
+	text := "**Mods updated**\n- Mod A (111)\n**Mods failed**\n- Mod B (222): timeout\n"
+	tests := []struct {
+		name string
+		cfg  config.Notification
+		want any
+	}{
+		{
+			name: "discord",
+			cfg:  config.Notification{Type: "discord"},
+			want: map[string]any{"content": text},
+		},
+		{
+			name: "slack",
+			cfg:  config.Notification{Type: "slack"},
+			want: map[string]any{"text": strings.ReplaceAll(text, "**", "*")},
+		},
+		{
+			name: "webhook template",
+			cfg: config.Notification{
+				Type:     "webhook",
+				Template: `{"updated": {{ len .Updated }}, "error": {{ json (index .Failed 0).Error }}}`,
+			},
+			want: map[string]any{"updated": 1.0, "error": "timeout"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, requests := receive(t, http.StatusNoContent)
+			tt.cfg.URL = url
+			tt.cfg.Headers = map[string]string{"Authorization": "Bearer token"}
+			n, err := NewNotifier(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := n.Notify(context.Background(), summary()); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+
+			r := <-requests
+			if got := r.header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := r.header.Get("Authorization"); got != "Bearer token" {
+				t.Errorf("Authorization = %q, want Bearer token", got)
+			}
+			var got any
+			if err := json.Unmarshal(r.body, &got); err != nil {
+				t.Fatalf("invalid JSON body %s: %v", r.body, err)
+			}
+			if !equalJSON(got, tt.want) {
+				t.Errorf("body = %s, want %v", r.body, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	url, requests := receive(t, http.StatusOK)
+	n, err := NewNotifier(&config.Notification{Type: "webhook", URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), summary()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	var m Message
+	if err := json.Unmarshal((<-requests).body, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Summary == nil || m.Summary.Items != 2 {
+		t.Errorf("summary = %+v, want 2 items", m.Summary)
+	}
+	if len(m.Updated) != 1 || m.Updated[0].WorkshopID != "111" {
+		t.Errorf("updated = %+v, want mod 111", m.Updated)
+	}
+	if len(m.Failed) != 1 || m.Failed[0].WorkshopID != "222" {
+		t.Errorf("failed = %+v, want mod 222", m.Failed)
+	}
+}
+
+func TestNotifyStatus(t *testing.T) {
+	url, _ := receive(t, http.StatusBadRequest)
+	n, err := NewNotifier(&config.Notification{Type: "discord", URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), summary()); err == nil {
+		t.Error("Notify() error = nil, want error of status 400")
+	}
+}
+
+func TestTextTruncate(t *testing.T) {
+	s := summary()
+	// multi-byte titles so the cut would split runes at most offsets
+	for i := 0; i < 200; i++ {
+		s.Results = append(s.Results, &event.Result{WorkshopID: "333", Title: "Мод 模组 🎮", Updated: true})
+	}
+	text := NewMessage(s).Text()
+	if !utf8.ValidString(text) {
+		t.Error("Text() is not valid UTF-8")
+	}
+	if len(text) > maxMessageLength+len("\n...") {
+		t.Errorf("len(Text()) = %d, want at most %d", len(text), maxMessageLength+len("\n..."))
+	}
+	if !strings.HasSuffix(text, "\n...") {
+		t.Error("Text() does not end with an ellipsis")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{text: "abc", n: 3, want: "abc"},
+		{text: "abcd", n: 3, want: "abc\n..."},
+		{text: "aé", n: 2, want: "a\n..."},
+		{text: "a🎮", n: 4, want: "a\n..."},
+		{text: "a🎮b", n: 5, want: "a🎮\n..."},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+// equalJSON compares decoded JSON values
+func equalJSON(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
@@ -1,19 +1,27 @@
 package output
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"sync"
+	"time"
 )
 
 type Output string
 
 const (
-	YAML Output = "yaml"
-	JSON Output = "json"
+	YAML  Output = "yaml"
+	JSON  Output = "json"
+	JSONL Output = "jsonl"
 )
 
 var (
-	InvalidFormatErr = errors.New(`invalid output format, must be "yaml" or "json"`)
+	InvalidFormatErr = errors.New(`invalid output format, must be "yaml", "json" or "jsonl"`)
 )
 
 // String returns the string representation of the output
@@ -26,7 +34,7 @@ func (o *Output) String() string {
 // it is used to implement the flag.Value interface
 func (o *Output) Set(v string) error {
 	switch v {
-	case "yaml", "json":
+	case "yaml", "json", "jsonl":
 		*o = Output(v)
 		return nil
 	default:
@@ -46,7 +54,56 @@ func (o *Output) Print(cfg *config.Config) error {
 		return cfg.PrintYAML()
 	case JSON:
 		return cfg.PrintJSON()
+	case JSONL:
+		return o.Write(os.Stdout, cfg)
 	default:
 		return InvalidFormatErr
 	}
 }
+
+// Write writes v to w in the output format, jsonl writes v as a single line
+func (o *Output) Write(w io.Writer, v any) error {
+	switch *o {
+	case YAML:
+		return yaml.NewEncoder(w).Encode(v)
+	case JSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(v)
+	case JSONL:
+		return json.NewEncoder(w).Encode(v)
+	default:
+		return InvalidFormatErr
+	}
+}
+
+// progressInterval is the minimum time between two progress events of the same item in an event stream
+const progressInterval = time.Second
+
+// Events returns a handler writing every event as a JSON line to w for jsonl, nil for other outputs.
+// Progress events are written at most once per second and item.
+func (o *Output) Events(w io.Writer) event.Handler {
+	if *o != JSONL {
+		return nil
+	}
+
+	var mu sync.Mutex
+	e := json.NewEncoder(w)
+	last := make(map[string]time.Time)
+
+	return func(ev event.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if ev.Type == event.ItemProgress || ev.Type == event.ItemCopying {
+			key := string(ev.Type) + ev.WorkshopID
+			// always write the last copy progress
+			if ev.Time.Sub(last[key]) < progressInterval && ev.Done != ev.Total {
+				return
+			}
+			last[key] = ev.Time
+		}
+
+		_ = e.Encode(ev)
+	}
+}
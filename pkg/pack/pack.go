@@ -0,0 +1,308 @@
+// Package pack exports mods from the workshop cache of steamcmd to a zip, tar.gz or tar.zst archive and installs such mod packs
+// without steamcmd.
+//
+// A mod pack contains manifest.json and the mods folder of every mod in mods/<app id>/<workshop id>.
+package pack
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/archive"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/manifest"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"github.com/Cehir/steam-workshop-downloader/pkg/verify"
+	logger "github.com/sirupsen/logrus"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ManifestName is the name of the manifest in a mod pack
+const ManifestName = "manifest.json"
+
+// Version is the version of the mod pack format
+const Version = 1
+
+var (
+	InvalidPackErr = errors.New("invalid mod pack")
+	NotCachedErr   = errors.New("mods are not in the workshop cache, download them first")
+)
+
+// Manifest describes the mods of a pack
+type Manifest struct {
+	Version int       `json:"version" yaml:"version"` // version of the format
+	Created time.Time `json:"created" yaml:"created"` // time the pack was exported
+	Apps    []*App    `json:"apps" yaml:"apps"`       // apps with their mods
+}
+
+// App is an app with its mods in a pack
+type App struct {
+	AppID string `json:"app_id" yaml:"app_id"`                 // Steam App ID
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"` // name of the game
+	Mods  []*Mod `json:"mods" yaml:"mods"`                     // mods of the app
+}
+
+// Mod is a mod in a pack
+type Mod struct {
+	WorkshopID  string            `json:"workshop_id" yaml:"workshop_id"`                       // Steam Workshop ID
+	Name        string            `json:"name,omitempty" yaml:"name,omitempty"`                 // name of the mod in the config
+	Title       string            `json:"title,omitempty" yaml:"title,omitempty"`               // workshop title
+	TimeUpdated int64             `json:"time_updated,omitempty" yaml:"time_updated,omitempty"` // workshop unix time of the version, 0 if unknown
+	Bytes       int64             `json:"bytes" yaml:"bytes"`                                   // size of the files
+	Files       map[string]string `json:"files" yaml:"files"`                                   // hex SHA-256 of the files by path relative to the mod
+}
+
+// dir returns the folder of a mod in a pack
+func dir(appID, workshopID string) string {
+	return "mods/" + appID + "/" + workshopID
+}
+
+// Export writes the mods of the apps in cfg from the workshop cache to a pack in the format.
+// Versions are taken from the workshop manifests of steamcmd, titles from the state.
+func Export(cfg *config.Config, st *state.State, w io.Writer, format archive.Format) (*Manifest, error) {
+	m := &Manifest{Version: Version, Created: time.Now().UTC()}
+	sources := map[*Mod]string{}
+	var missing []string
+	for _, app := range cfg.Apps {
+		acf, err := manifest.Load(cfg.Steam.ManifestPath(app.AppID))
+		if err != nil {
+			return nil, err
+		}
+		a := &App{AppID: app.AppID, Name: app.Name}
+		for _, mod := range app.Mods {
+			src := filepath.Join(cfg.Steam.ModDir(app.AppID, mod.WorkshopID), "mods")
+			if _, err := os.Stat(src); err != nil {
+				missing = append(missing, mod.WorkshopID)
+				continue
+			}
+			pm := &Mod{WorkshopID: mod.WorkshopID, Name: mod.Name}
+			if item := st.Get(mod.WorkshopID); item != nil {
+				pm.Title = item.Title
+				pm.TimeUpdated = item.TimeUpdated
+			}
+			if item := acf.Get(mod.WorkshopID); item != nil && item.TimeUpdated != 0 {
+				pm.TimeUpdated = item.TimeUpdated
+			}
+			if pm.Files, pm.Bytes, err = hashes(src); err != nil {
+				return nil, fmt.Errorf("failed to read mod %s: %w", mod.WorkshopID, err)
+			}
+			sources[pm] = src
+			a.Mods = append(a.Mods, pm)
+		}
+		if len(a.Mods) > 0 {
+			m.Apps = append(m.Apps, a)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", NotCachedErr, strings.Join(missing, ", "))
+	}
+	if len(m.Apps) == 0 {
+		return nil, fmt.Errorf("no mods to export")
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	aw, err := archive.NewWriter(w, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := aw.AddBytes(ManifestName, b); err != nil {
+		return nil, err
+	}
+	for _, a := range m.Apps {
+		for _, mod := range a.Mods {
+			logger.WithField("workshop_id", mod.WorkshopID).Debug("exporting mod")
+			if err := aw.AddDir(sources[mod], dir(a.AppID, mod.WorkshopID)+"/"); err != nil {
+				return nil, fmt.Errorf("failed to export mod %s: %w", mod.WorkshopID, err)
+			}
+		}
+	}
+	return m, aw.Close()
+}
+
+// hashes returns the hex SHA-256 of the files in dir by their relative path and their total size
+func hashes(dir string) (map[string]string, int64, error) {
+	files := map[string]string{}
+	var size int64
+	err := archive.Walk(dir, func(rel, path string, info fs.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		sum, err := verify.Hash(path)
+		if err != nil {
+			return err
+		}
+		files[rel] = hex.EncodeToString(sum)
+		size += info.Size()
+		return nil
+	})
+	return files, size, err
+}
+
+// Pack is an extracted mod pack
+type Pack struct {
+	Manifest *Manifest
+	dir      string
+}
+
+// Open extracts the pack at src to a temporary folder and verifies the files of all mods against the manifest.
+// The pack has to be closed to remove the folder.
+func Open(src string) (*Pack, error) {
+	tmp, err := os.MkdirTemp("", "swd-pack-*")
+	if err != nil {
+		return nil, err
+	}
+	p := &Pack{dir: tmp}
+	if err := p.open(src); err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pack) open(src string) error {
+	if err := archive.Extract(src, p.dir); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", src, err)
+	}
+	b, err := os.ReadFile(filepath.Join(p.dir, ManifestName))
+	if err != nil {
+		return fmt.Errorf("%w: %s", InvalidPackErr, err)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return fmt.Errorf("%w: failed to parse manifest: %s", InvalidPackErr, err)
+	}
+	if m.Version < 1 || m.Version > Version {
+		return fmt.Errorf("%w: unsupported version %d", InvalidPackErr, m.Version)
+	}
+	p.Manifest = m
+
+	for _, a := range m.Apps {
+		if !numeric(a.AppID) {
+			return fmt.Errorf("%w: invalid app id %q", InvalidPackErr, a.AppID)
+		}
+		for _, mod := range a.Mods {
+			if !numeric(mod.WorkshopID) {
+				return fmt.Errorf("%w: invalid workshop id %q", InvalidPackErr, mod.WorkshopID)
+			}
+			if err := p.verify(a.AppID, mod); err != nil {
+				return fmt.Errorf("%w: mod %s: %s", InvalidPackErr, mod.WorkshopID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// verify compares the files of a mod with the hashes of the manifest
+func (p *Pack) verify(appID string, mod *Mod) error {
+	files, _, err := hashes(filepath.Join(p.dir, filepath.FromSlash(dir(appID, mod.WorkshopID))))
+	if err != nil {
+		return err
+	}
+	for name, sum := range mod.Files {
+		got, ok := files[name]
+		switch {
+		case !ok:
+			return fmt.Errorf("missing %s", name)
+		case got != sum:
+			return fmt.Errorf("checksum mismatch of %s", name)
+		}
+	}
+	for name := range files {
+		if _, ok := mod.Files[name]; !ok {
+			return fmt.Errorf("unexpected %s", name)
+		}
+	}
+	return nil
+}
+
+// numeric returns true if s is a non-empty string of digits, ids are used as folder names
+func numeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Close removes the extracted pack
+func (p *Pack) Close() error {
+	return os.RemoveAll(p.dir)
+}
+
+// Result is the result of installing a mod of a pack
+type Result struct {
+	AppID      string `json:"app_id" yaml:"app_id"`                   // Steam App ID
+	WorkshopID string `json:"workshop_id" yaml:"workshop_id"`         // Steam Workshop ID
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`   // name of the mod
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`   // destination of the mod
+	Installed  bool   `json:"installed" yaml:"installed"`             // the mod was installed
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"` // error while installing the mod
+}
+
+// Install installs the mods of the app from the pack to the path of the app and records them in the state.
+// Apps installing with symlinks get copies, the extracted pack is removed on Close.
+func (p *Pack) Install(app *config.App, st *state.State) ([]*Result, error) {
+	var results []*Result
+	var errs path.Errors
+	for _, a := range p.Manifest.Apps {
+		if a.AppID != app.AppID {
+			continue
+		}
+		opts := app.InstallOptions()
+		if opts.Mode == path.Symlink {
+			opts.Mode = path.Copy
+		}
+		for _, mod := range a.Mods {
+			r := &Result{AppID: a.AppID, WorkshopID: mod.WorkshopID, Name: mod.Name, Path: app.Path}
+			if r.Name == "" {
+				r.Name = mod.Title
+			}
+			results = append(results, r)
+
+			src := filepath.Join(p.dir, filepath.FromSlash(dir(a.AppID, mod.WorkshopID)))
+			if err := path.Install(src, app.Path, opts); err != nil {
+				r.Error = err.Error()
+				errs = append(errs, fmt.Errorf("failed to install mod %s: %w", mod.WorkshopID, err))
+				continue
+			}
+			r.Installed = true
+			st.Set(&state.Item{
+				AppID:       a.AppID,
+				WorkshopID:  mod.WorkshopID,
+				Title:       mod.Title,
+				TimeUpdated: mod.TimeUpdated,
+				Installed:   time.Now(),
+				Path:        app.Path,
+				Bytes:       mod.Bytes,
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+// Has returns true if the pack contains mods of the app
+func (p *Pack) Has(appID string) bool {
+	for _, a := range p.Manifest.Apps {
+		if a.AppID == appID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package path
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Free returns the bytes available on the volume of path, a path that does not exist yet uses its nearest existing parent
+func Free(path string) (int64, error) {
+	dir, err := Existing(path)
+	if err != nil {
+		return 0, err
+	}
+	return free(dir)
+}
+
+// Volume returns the id of the volume of path, a path that does not exist yet uses its nearest existing parent
+func Volume(path string) (uint64, error) {
+	dir, err := Existing(path)
+	if err != nil {
+		return 0, err
+	}
+	return volume(dir)
+}
+
+// Existing returns path or its nearest existing parent
+func Existing(path string) (string, error) {
+	path = filepath.Clean(path)
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package path
+
+// free is only supported on linux, darwin and windows
+func free(dir string) (int64, error) {
+	return 0, NotSupportedErr
+}
+
+// volume is only supported on linux, darwin and windows
+func volume(dir string) (uint64, error) {
+	return 0, NotSupportedErr
+}
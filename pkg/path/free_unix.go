@@ -0,0 +1,23 @@
+//go:build linux || darwin
+
+package path
+
+import "syscall"
+
+// free returns the bytes available to unprivileged users on the volume of dir
+func free(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// volume returns the device id of the volume of dir
+func volume(dir string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
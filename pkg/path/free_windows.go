@@ -0,0 +1,35 @@
+//go:build windows
+
+package path
+
+import "golang.org/x/sys/windows"
+
+// free returns the bytes available to the current user on the volume of dir
+func free(dir string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return int64(available), nil
+}
+
+// volume returns the serial number of the volume of dir, folders mounted from other volumes have their serial number
+func volume(dir string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	root := make([]uint16, windows.MAX_LONG_PATH)
+	if err := windows.GetVolumePathName(p, &root[0], uint32(len(root))); err != nil {
+		return 0, err
+	}
+	var serial uint32
+	if err := windows.GetVolumeInformation(&root[0], nil, 0, &serial, nil, nil, nil, 0); err != nil {
+		return 0, err
+	}
+	return uint64(serial), nil
+}
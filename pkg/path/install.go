@@ -0,0 +1,414 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mode is the way files are installed to their destination
+type Mode string
+
+const (
+	Copy     Mode = "copy"     // copy the files, this is the default
+	Hardlink Mode = "hardlink" // hard link the files, falls back to copy across filesystems
+	Reflink  Mode = "reflink"  // clone the files on filesystems with copy on write, falls back to copy
+	Symlink  Mode = "symlink"  // link the top level folders to the source, falls back to copy
+)
+
+// SymlinkPolicy decides how symbolic links inside the source are installed
+type SymlinkPolicy string
+
+const (
+	Follow   SymlinkPolicy = "follow"   // install the target of the link
+	Internal SymlinkPolicy = "internal" // recreate links pointing inside the source, reject others, this is the default
+	Skip     SymlinkPolicy = "skip"     // ignore links
+)
+
+var (
+	NotSupportedErr = errors.New("not supported on this platform")
+	EscapeErr       = errors.New("path escapes the destination")
+	LoopErr         = errors.New("symbolic link loop")
+)
+
+// Options of Install
+type Options struct {
+	Mode     Mode                    // how files are installed, default is Copy
+	Symlinks SymlinkPolicy           // how links in the source are installed, default is Internal
+	Progress func(done, total int64) // called with the bytes installed so far and the total size of src, may be nil
+}
+
+// Errors are the errors of all files that could not be installed
+type Errors []error
+
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Is returns true if one of the errors matches target
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target
+func (e Errors) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// installer installs the files of a directory and remembers if it had to fall back to copying
+type installer struct {
+	src      string
+	dst      string
+	mode     Mode
+	symlinks SymlinkPolicy
+	counter  *progressWriter
+	fallback bool
+	errs     Errors
+	visiting map[string]bool // resolved folders on the current path to detect loops
+}
+
+// Install installs the content of src to dst. src should be a full path.
+// Files are written to temporary files that are renamed when complete, modification times are preserved.
+// Installing continues after errors, the errors of all files are returned as Errors.
+func Install(src, dst string, opts Options) error {
+	src, dst = filepath.Clean(src), filepath.Clean(dst)
+	in := &installer{
+		src:      src,
+		dst:      dst,
+		mode:     opts.Mode,
+		symlinks: opts.Symlinks,
+		visiting: map[string]bool{},
+	}
+	if in.mode == "" {
+		in.mode = Copy
+	}
+	if in.symlinks == "" {
+		in.symlinks = Internal
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+	if opts.Progress != nil {
+		total, err := Size(src)
+		if err != nil {
+			return err
+		}
+		in.counter = &progressWriter{total: total, progress: opts.Progress}
+		opts.Progress(0, total)
+	}
+
+	in.dir(src, dst, info, true)
+	if len(in.errs) > 0 {
+		return in.errs
+	}
+	return nil
+}
+
+// fail records the error of a file
+func (in *installer) fail(path string, err error) {
+	logger.WithError(err).WithField("path", path).Debug("failed to install")
+	in.errs = append(in.errs, fmt.Errorf("%s: %w", path, err))
+}
+
+// target returns the destination of name in dir, it rejects paths escaping the destination
+func (in *installer) target(dir, name string) (string, error) {
+	outpath := filepath.Join(dir, name)
+	if !Within(in.dst, outpath) {
+		return "", EscapeErr
+	}
+	return outpath, nil
+}
+
+// dir installs the entries of the folder path to outpath, the modification time is set afterwards.
+// top is true for the source itself.
+func (in *installer) dir(path, outpath string, info fs.FileInfo, top bool) {
+	// replace a link of a previous symlink install instead of writing through it,
+	// the destination itself may be a link to another volume
+	if out, err := os.Lstat(outpath); !top && err == nil && out.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(outpath); err != nil {
+			in.fail(outpath, err)
+			return
+		}
+	}
+	if err := os.MkdirAll(outpath, info.Mode().Perm()|0700); err != nil {
+		in.fail(outpath, err)
+		return
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		in.fail(path, err)
+		return
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		in.visiting[real] = true
+		defer delete(in.visiting, real)
+	}
+	for _, entry := range entries {
+		p := filepath.Join(path, entry.Name())
+		out, err := in.target(outpath, entry.Name())
+		if err != nil {
+			in.fail(p, err)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			in.fail(p, err)
+			continue
+		}
+		logger.WithFields(logger.Fields{
+			"from": p,
+			"to":   out,
+			"mode": in.mode,
+		}).Debug("installing")
+
+		// link top level folders and files in symlink mode
+		if in.mode == Symlink && top {
+			err := in.symlink(p, out, info)
+			if err == nil {
+				continue
+			}
+			in.fallBack(err)
+		}
+		in.entry(p, out, info)
+	}
+
+	// the destination is shared by all mods and keeps its time
+	if top {
+		return
+	}
+	if err := os.Chtimes(outpath, info.ModTime(), info.ModTime()); err != nil {
+		in.fail(outpath, err)
+	}
+}
+
+// entry installs a single folder, file or link
+func (in *installer) entry(path, outpath string, info fs.FileInfo) {
+	switch {
+	case info.IsDir():
+		in.dir(path, outpath, info, false)
+	case info.Mode()&os.ModeSymlink != 0:
+		in.link(path, outpath)
+	case info.Mode().IsRegular():
+		if err := in.file(path, outpath, info); err != nil {
+			in.fail(path, err)
+		}
+	}
+}
+
+// link installs a symbolic link of the source with the symlink policy
+func (in *installer) link(path, outpath string) {
+	switch in.symlinks {
+	case Skip:
+		logger.WithField("path", path).Debug("skipping symbolic link")
+	case Follow:
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			in.fail(path, err)
+			return
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			in.fail(path, err)
+			return
+		}
+		if info.IsDir() && in.visiting[target] {
+			in.fail(path, LoopErr)
+			return
+		}
+		in.entry(target, outpath, info)
+	default:
+		link, err := os.Readlink(path)
+		if err != nil {
+			in.fail(path, err)
+			return
+		}
+		target := link
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if !Within(in.src, target) {
+			in.fail(path, fmt.Errorf("%w: symbolic link to %s outside of the source", EscapeErr, link))
+			return
+		}
+		// links are recreated relative to stay inside the destination
+		rel, err := filepath.Rel(filepath.Dir(path), target)
+		if err != nil {
+			in.fail(path, err)
+			return
+		}
+		if err := replace(outpath, func(tmp string) error { return os.Symlink(rel, tmp) }); err != nil {
+			in.fail(path, err)
+		}
+	}
+}
+
+// fallBack switches to copy mode after a link failed
+func (in *installer) fallBack(err error) {
+	if !in.fallback {
+		logger.WithError(err).WithField("mode", in.mode).Info("falling back to copy")
+	}
+	in.fallback = true
+	in.mode = Copy
+}
+
+// file installs a regular file with the mode of the installer
+func (in *installer) file(path, outpath string, info fs.FileInfo) error {
+	switch in.mode {
+	case Hardlink:
+		err := replace(outpath, func(tmp string) error { return os.Link(path, tmp) })
+		if err == nil {
+			in.count(info.Size())
+			return nil
+		}
+		in.fallBack(err)
+	case Reflink:
+		err := replace(outpath, func(tmp string) error {
+			if err := reflink(path, tmp, info.Mode()); err != nil {
+				return err
+			}
+			return os.Chtimes(tmp, info.ModTime(), info.ModTime())
+		})
+		if err == nil {
+			in.count(info.Size())
+			return nil
+		}
+		in.fallBack(err)
+	}
+	return replace(outpath, func(tmp string) error { return in.copy(path, tmp, info) })
+}
+
+// replace creates a temporary file next to outpath with create and renames it to outpath.
+// A link at outpath is replaced instead of written through, the temporary file is removed on errors.
+func replace(outpath string, create func(tmp string) error) error {
+	tmp, err := tempName(outpath)
+	if err != nil {
+		return err
+	}
+	if err := create(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, outpath); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// tempName returns an unused name for a temporary file next to path
+func tempName(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return name, os.Remove(name)
+}
+
+// symlink links outpath to path, an existing file, link or folder at outpath is replaced
+func (in *installer) symlink(path, outpath string, info fs.FileInfo) error {
+	if out, err := os.Lstat(outpath); err == nil {
+		if out.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Readlink(outpath); err == nil && target == path {
+				in.count(dirSize(path, info))
+				return nil
+			}
+		}
+		if err := os.RemoveAll(outpath); err != nil {
+			return err
+		}
+	}
+	if err := os.Symlink(path, outpath); err != nil {
+		return fmt.Errorf("failed to link %s: %w", outpath, err)
+	}
+	in.count(dirSize(path, info))
+	return nil
+}
+
+// dirSize returns the size of a file or folder for the progress, errors are ignored
+func dirSize(path string, info fs.FileInfo) int64 {
+	if !info.IsDir() {
+		return info.Size()
+	}
+	size, _ := Size(path)
+	return size
+}
+
+// count reports installed bytes to the progress
+func (in *installer) count(n int64) {
+	if in.counter != nil {
+		in.counter.add(n)
+	}
+}
+
+// copy copies the contents of a regular file to the new file outpath and syncs it to disk
+func (in *installer) copy(path, outpath string, info fs.FileInfo) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(src *os.File) {
+		_ = src.Close()
+	}(src)
+
+	fh, err := os.OpenFile(outpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = fh
+	if in.counter != nil {
+		w = io.MultiWriter(fh, in.counter)
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		_ = fh.Close()
+		return err
+	}
+	if err := fh.Chmod(info.Mode().Perm()); err != nil {
+		_ = fh.Close()
+		return err
+	}
+	if err := fh.Sync(); err != nil {
+		_ = fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(outpath, info.ModTime(), info.ModTime())
+}
+
+// Within returns true if path is dir or inside of dir
+func Within(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,262 @@
+package path
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// write creates the files in dir with their content, names use slashes
+func write(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// read returns the content of the file or fails the test
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// link creates a symbolic link or skips the test if links are not supported
+func link(t *testing.T, target, name string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, name); err != nil {
+		t.Skip("symbolic links are not supported:", err)
+	}
+}
+
+func TestInstallSymlinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		symlinks SymlinkPolicy
+		setup    func(t *testing.T, src, outside string)
+		check    func(t *testing.T, dst string)
+		wantErr  error
+	}{
+		{
+			name: "escaping link is rejected",
+			setup: func(t *testing.T, src, outside string) {
+				link(t, outside, filepath.Join(src, "modA", "escape"))
+			},
+			check: func(t *testing.T, dst string) {
+				if _, err := os.Lstat(filepath.Join(dst, "modA", "escape")); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("escaping link was installed: %v", err)
+				}
+				if got := read(t, filepath.Join(dst, "modA", "info.txt")); got != "a" {
+					t.Errorf("other files of the mod = %q, want a", got)
+				}
+			},
+			wantErr: EscapeErr,
+		},
+		{
+			name: "relative escaping link is rejected",
+			setup: func(t *testing.T, src, outside string) {
+				link(t, filepath.Join("..", "..", filepath.Base(outside)), filepath.Join(src, "modA", "escape"))
+			},
+			wantErr: EscapeErr,
+		},
+		{
+			name:     "escaping link is skipped",
+			symlinks: Skip,
+			setup: func(t *testing.T, src, outside string) {
+				link(t, outside, filepath.Join(src, "modA", "escape"))
+			},
+			check: func(t *testing.T, dst string) {
+				if _, err := os.Lstat(filepath.Join(dst, "modA", "escape")); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("skipped link was installed: %v", err)
+				}
+			},
+		},
+		{
+			name:     "escaping link is followed",
+			symlinks: Follow,
+			setup: func(t *testing.T, src, outside string) {
+				link(t, outside, filepath.Join(src, "modA", "escape"))
+			},
+			check: func(t *testing.T, dst string) {
+				if got := read(t, filepath.Join(dst, "modA", "escape", "secret.txt")); got != "outside" {
+					t.Errorf("followed file = %q, want outside", got)
+				}
+			},
+		},
+		{
+			name: "internal links are recreated relative",
+			setup: func(t *testing.T, src, outside string) {
+				link(t, "info.txt", filepath.Join(src, "modA", "relative"))
+				link(t, filepath.Join(src, "modA", "info.txt"), filepath.Join(src, "modA", "absolute"))
+				link(t, filepath.Join("..", "modA"), filepath.Join(src, "modB", "modA"))
+			},
+			check: func(t *testing.T, dst string) {
+				for name, want := range map[string]string{
+					"modA/relative": "info.txt",
+					"modA/absolute": "info.txt",
+					"modB/modA":     filepath.Join("..", "modA"),
+				} {
+					got, err := os.Readlink(filepath.Join(dst, filepath.FromSlash(name)))
+					if err != nil || got != want {
+						t.Errorf("link %s = %q, %v, want %q", name, got, err, want)
+					}
+				}
+				if got := read(t, filepath.Join(dst, "modA", "absolute")); got != "a" {
+					t.Errorf("installed link points to %q, want a", got)
+				}
+			},
+		},
+		{
+			name:     "loop is detected",
+			symlinks: Follow,
+			setup: func(t *testing.T, src, outside string) {
+				link(t, "..", filepath.Join(src, "modA", "loop"))
+			},
+			check: func(t *testing.T, dst string) {
+				if got := read(t, filepath.Join(dst, "modA", "info.txt")); got != "a" {
+					t.Errorf("files next to the loop = %q, want a", got)
+				}
+			},
+			wantErr: LoopErr,
+		},
+		{
+			name: "link to itself is recreated",
+			setup: func(t *testing.T, src, outside string) {
+				link(t, "..", filepath.Join(src, "modA", "loop"))
+			},
+			check: func(t *testing.T, dst string) {
+				if got, err := os.Readlink(filepath.Join(dst, "modA", "loop")); err != nil || got != ".." {
+					t.Errorf("link = %q, %v, want ..", got, err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst, outside := t.TempDir(), t.TempDir(), t.TempDir()
+			write(t, src, map[string]string{"modA/info.txt": "a", "modB/info.txt": "b"})
+			write(t, outside, map[string]string{"secret.txt": "outside"})
+			tt.setup(t, src, outside)
+
+			err := Install(src, dst, Options{Symlinks: tt.symlinks})
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Install() error = %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Install() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, dst)
+			}
+		})
+	}
+}
+
+// failed files are reported together, the other files are installed and no partial file is left behind
+func TestInstallErrors(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	write(t, src, map[string]string{"modA/info.txt": "new", "modA/data.txt": "new", "modB/info.txt": "b"})
+	// a folder where a file is installed cannot be replaced
+	write(t, dst, map[string]string{"modA/data.txt/keep.txt": "old"})
+	link(t, "missing", filepath.Join(src, "modB", "broken"))
+
+	err := Install(src, dst, Options{Symlinks: Follow})
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Install() error = %v, want Errors of two files", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("Install() error = %v, want an *fs.PathError", err)
+	}
+	if errors.Is(err, LoopErr) || errors.Is(err, EscapeErr) {
+		t.Errorf("Install() error = %v matches an error it does not have", err)
+	}
+	for _, name := range []string{"data.txt", "broken"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Install() error = %v, want it to name %s", err, name)
+		}
+	}
+
+	if got := read(t, filepath.Join(dst, "modA", "info.txt")); got != "new" {
+		t.Errorf("other file of the mod = %q, want new", got)
+	}
+	if got := read(t, filepath.Join(dst, "modA", "data.txt", "keep.txt")); got != "old" {
+		t.Errorf("folder in the way = %q, want it unchanged", got)
+	}
+	if got := read(t, filepath.Join(dst, "modB", "info.txt")); got != "b" {
+		t.Errorf("other mod = %q, want b", got)
+	}
+	_ = filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".tmp") {
+			t.Errorf("temporary file %s was left behind", path)
+		}
+		return nil
+	})
+}
+
+// a destination linked to a folder on another disk is installed into, the link is kept
+func TestInstallLinkedDestination(t *testing.T) {
+	src, target := t.TempDir(), t.TempDir()
+	write(t, src, map[string]string{"modA/info.txt": "a"})
+	dst := filepath.Join(t.TempDir(), "mods")
+	if err := os.Symlink(target, dst); err != nil {
+		t.Skip("symbolic links are not supported:", err)
+	}
+
+	for _, mode := range []Mode{Copy, Hardlink, Symlink} {
+		if err := Install(src, dst, Options{Mode: mode}); err != nil {
+			t.Fatalf("Install(%s) error = %v", mode, err)
+		}
+		info, err := os.Lstat(dst)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("Install(%s) replaced the linked destination", mode)
+		}
+		if got := read(t, filepath.Join(target, "modA", "info.txt")); got != "a" {
+			t.Errorf("Install(%s) installed %q to the target of the destination, want a", mode, got)
+		}
+	}
+}
+
+// links of a previous symlink install are replaced, the files they point to are not changed
+func TestInstallReplacesLinksBelowDestination(t *testing.T) {
+	content, src, dst := t.TempDir(), t.TempDir(), t.TempDir()
+	write(t, content, map[string]string{"modA/info.txt": "cached"})
+	write(t, src, map[string]string{"modA/info.txt": "new"})
+
+	if err := Install(content, dst, Options{Mode: Symlink}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "modA")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink install did not link the mod")
+	}
+
+	if err := Install(src, dst, Options{}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	info, err := os.Lstat(filepath.Join(dst, "modA"))
+	if err != nil || !info.IsDir() {
+		t.Fatal("Install() did not replace the link of the mod with a folder")
+	}
+	if got := read(t, filepath.Join(dst, "modA", "info.txt")); got != "new" {
+		t.Errorf("installed file = %q, want new", got)
+	}
+	if got := read(t, filepath.Join(content, "modA", "info.txt")); got != "cached" {
+		t.Errorf("file in the workshop cache = %q, want it unchanged", got)
+	}
+}
@@ -1,8 +1,6 @@
 package path
 
 import (
-	logger "github.com/sirupsen/logrus"
-	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -59,70 +57,46 @@ func (p *Path) Absolute(path string) (string, error) {
 	return filepath.Clean(fp), nil
 }
 
-// CopyDir copies the content of src to dst. src should be a full path.
-func CopyDir(src, dst string) error {
-
-	return filepath.Walk(src, func(path string, info fs.FileInfo, err error) error {
+// Size returns the total size of all regular files in dir
+func Size(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-
-		// copy to this path
-		outpath := filepath.Join(dst, strings.TrimPrefix(path, src))
-		logger.WithFields(logger.Fields{
-			"from": path,
-			"to":   outpath,
-		}).Debug("copying")
-
-		if info.IsDir() {
-			err := os.MkdirAll(outpath, info.Mode())
-			if err != nil {
-				return err
-			}
-			return nil // means recursive
-		}
-
-		// handle irregular files
-		if !info.Mode().IsRegular() {
-			switch info.Mode().Type() & os.ModeType {
-			case os.ModeSymlink:
-				link, err := os.Readlink(path)
-				if err != nil {
-					return err
-				}
-				return os.Symlink(link, outpath)
-			}
-			return nil
+		if info.Mode().IsRegular() {
+			size += info.Size()
 		}
+		return nil
+	})
+	return size, err
+}
 
-		// copy contents of regular file efficiently
+// CopyDir copies the content of src to dst. src should be a full path.
+func CopyDir(src, dst string) error {
+	return CopyDirProgress(src, dst, nil)
+}
 
-		// open input
-		in, _ := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer func(in *os.File) {
-			_ = in.Close()
-		}(in)
+// CopyDirProgress copies the content of src to dst like CopyDir.
+// progress is called with the bytes copied so far and the total size of src, it may be nil.
+func CopyDirProgress(src, dst string, progress func(done, total int64)) error {
+	return Install(src, dst, Options{Progress: progress})
+}
 
-		// create output
-		fh, err := os.Create(outpath)
-		if err != nil {
-			return err
-		}
-		defer func(fh *os.File) {
-			_ = fh.Close()
-		}(fh)
+// progressWriter counts the bytes written to it and reports them
+type progressWriter struct {
+	done     int64
+	total    int64
+	progress func(done, total int64)
+}
 
-		// make it the same
-		err = fh.Chmod(info.Mode())
-		if err != nil {
-			return err
-		}
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.add(int64(len(p)))
+	return len(p), nil
+}
 
-		// copy content
-		_, err = io.Copy(fh, in)
-		return err
-	})
+// add reports n more bytes
+func (w *progressWriter) add(n int64) {
+	w.done += n
+	w.progress(w.done, w.total)
 }
@@ -0,0 +1,33 @@
+package path
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// ficlone is the ioctl FICLONE that clones a file on btrfs, xfs and other copy on write filesystems
+const ficlone = 0x40049409
+
+// reflink clones path to outpath, an existing outpath is replaced
+func reflink(path, outpath string, perm fs.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	dst, err := os.OpenFile(outpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd()); errno != 0 {
+		_ = dst.Close()
+		_ = os.Remove(outpath)
+		return errno
+	}
+	return dst.Close()
+}
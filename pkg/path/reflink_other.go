@@ -0,0 +1,12 @@
+//go:build !linux
+
+package path
+
+import (
+	"io/fs"
+)
+
+// reflink is only supported on linux
+func reflink(path, outpath string, perm fs.FileMode) error {
+	return NotSupportedErr
+}
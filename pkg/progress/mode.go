@@ -0,0 +1,52 @@
+package progress
+
+import (
+	"errors"
+	"os"
+)
+
+type Mode string
+
+const (
+	Auto  Mode = "auto"  // bars in a terminal, plain lines otherwise
+	Bar   Mode = "bar"   // always draw bars
+	Plain Mode = "plain" // always print plain-text lines
+	None  Mode = "none"  // no progress output
+)
+
+var (
+	InvalidModeErr = errors.New(`invalid progress mode, must be "auto", "bar", "plain" or "none"`)
+)
+
+// String returns the string representation of the mode
+// it is used to implement the flag.Value interface
+func (m *Mode) String() string {
+	return string(*m)
+}
+
+// Set sets the mode to the given value
+// it is used to implement the flag.Value interface
+func (m *Mode) Set(v string) error {
+	switch v {
+	case "auto", "bar", "plain", "none":
+		*m = Mode(v)
+		return nil
+	default:
+		return InvalidModeErr
+	}
+}
+
+// Type returns the type of the mode
+// it is used to implement the flag.Value interface
+func (m *Mode) Type() string {
+	return "mode"
+}
+
+// IsTerminal returns true if f is a character device like a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
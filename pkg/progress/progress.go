@@ -0,0 +1,216 @@
+package progress
+
+import (
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	barWidth       = 30                     // width of a progress bar in characters
+	redrawInterval = 100 * time.Millisecond // minimum time between two redraws in a terminal
+	plainInterval  = 5 * time.Second        // time between two plain-text progress lines
+)
+
+// item is an item that is currently downloaded or copied
+type item struct {
+	label string // name and workshop id
+	state string // downloading, downloaded or copying
+	done  int64  // bytes done
+	total int64  // bytes total
+}
+
+func (i *item) String() string {
+	if i.total <= 0 {
+		return fmt.Sprintf("%s %s", i.label, i.state)
+	}
+	return fmt.Sprintf("%s %s %5.1f%% (%s / %s)", i.label, i.state,
+		float64(i.done)/float64(i.total)*100, output.Bytes(i.done), output.Bytes(i.total))
+}
+
+// Display shows the progress of a download run.
+// In a terminal it draws an overall bar and a bar per active mod,
+// otherwise it prints plain-text lines periodically.
+type Display struct {
+	mu       sync.Mutex
+	w        io.Writer
+	tty      bool
+	started  time.Time
+	total    int              // number of items in the run
+	finished int              // number of copied or failed items
+	failed   int              // number of failed items
+	items    map[string]*item // active items by workshop id
+	order    []string         // workshop ids of active items in start order
+	lines    int              // number of lines drawn by the last redraw
+	last     time.Time        // time of the last redraw or progress line
+}
+
+// New returns a display writing to f for the given mode, nil for None
+func New(f *os.File, mode Mode) *Display {
+	d := &Display{
+		w:     f,
+		items: make(map[string]*item),
+	}
+	switch mode {
+	case None:
+		return nil
+	case Bar:
+		d.tty = true
+	case Plain:
+		d.tty = false
+	default:
+		d.tty = IsTerminal(f)
+	}
+	return d
+}
+
+// Handle updates the display with the given event, it implements event.Handler
+func (d *Display) Handle(e event.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch e.Type {
+	case event.RunStarted:
+		d.started = e.Time
+		d.total = e.Items
+		d.println(fmt.Sprintf("downloading %d mods", d.total))
+	case event.ItemStarted:
+		d.active(e).state = "downloading"
+		if !d.tty {
+			d.println(fmt.Sprintf("%s downloading", label(e)))
+		}
+		d.redraw(true)
+	case event.ItemProgress:
+		i := d.active(e)
+		i.state, i.done, i.total = "downloading", e.Done, e.Total
+		d.update()
+	case event.ItemDownloaded:
+		i := d.active(e)
+		i.state, i.done, i.total = "downloaded", e.Done, e.Total
+		if !d.tty {
+			d.println(i.String())
+		}
+		d.redraw(true)
+	case event.ItemCopying:
+		i := d.active(e)
+		i.state, i.done, i.total = "copying", e.Done, e.Total
+		d.update()
+	case event.ItemCopied:
+		d.done(e)
+		d.println(fmt.Sprintf("%s copied to %s", label(e), e.Path))
+	case event.ItemFailed:
+		d.done(e)
+		d.failed++
+		d.println(fmt.Sprintf("%s failed: %s", label(e), e.Error))
+	case event.RunFinished:
+		summary := fmt.Sprintf("finished %d/%d mods, %d failed in %s",
+			d.finished-d.failed, d.total, d.failed, e.Time.Sub(d.started).Round(time.Second))
+		if e.Error != "" {
+			summary += ": " + e.Error
+		}
+		// remove the bars, only the summary stays
+		if d.tty {
+			d.clear()
+		}
+		_, _ = fmt.Fprintln(d.w, summary)
+	}
+}
+
+// active returns the active item of the event and creates it if necessary
+func (d *Display) active(e event.Event) *item {
+	i, ok := d.items[e.WorkshopID]
+	if !ok {
+		i = &item{label: label(e)}
+		d.items[e.WorkshopID] = i
+		d.order = append(d.order, e.WorkshopID)
+	}
+	return i
+}
+
+// done removes the item of the event from the active items
+func (d *Display) done(e event.Event) {
+	d.finished++
+	delete(d.items, e.WorkshopID)
+	for n, id := range d.order {
+		if id == e.WorkshopID {
+			d.order = append(d.order[:n], d.order[n+1:]...)
+			break
+		}
+	}
+}
+
+// update redraws the bars or prints plain-text progress lines if enough time passed
+func (d *Display) update() {
+	if d.tty {
+		d.redraw(false)
+		return
+	}
+	if time.Since(d.last) < plainInterval {
+		return
+	}
+	d.last = time.Now()
+	_, _ = fmt.Fprintf(d.w, "progress: %d/%d mods\n", d.finished, d.total)
+	for _, id := range d.order {
+		_, _ = fmt.Fprintf(d.w, "  %s\n", d.items[id])
+	}
+}
+
+// println prints a permanent line above the bars
+func (d *Display) println(s string) {
+	if d.tty {
+		d.clear()
+	}
+	_, _ = fmt.Fprintln(d.w, s)
+	if d.tty {
+		d.redraw(true)
+	}
+}
+
+// clear removes the bars drawn by the last redraw
+func (d *Display) clear() {
+	_, _ = io.WriteString(d.w, strings.Repeat("\033[1A\033[2K", d.lines))
+	d.lines = 0
+}
+
+// redraw draws the overall bar and a bar for each active item in a terminal
+func (d *Display) redraw(force bool) {
+	if !d.tty || (!force && time.Since(d.last) < redrawInterval) {
+		return
+	}
+	d.last = time.Now()
+	d.clear()
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "%s %d/%d mods\n", bar(int64(d.finished), int64(d.total)), d.finished, d.total)
+	for _, id := range d.order {
+		i := d.items[id]
+		_, _ = fmt.Fprintf(&b, "%s %s\n", bar(i.done, i.total), i)
+	}
+	d.lines = 1 + len(d.order)
+	_, _ = io.WriteString(d.w, b.String())
+}
+
+// bar returns a progress bar for done of total
+func bar(done, total int64) string {
+	filled := 0
+	if total > 0 {
+		filled = int(float64(barWidth) * float64(done) / float64(total))
+	}
+	if filled > barWidth {
+		filled = barWidth
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "]"
+}
+
+// label returns the name and workshop id of the item of the event
+func label(e event.Event) string {
+	if e.Name == "" {
+		return e.WorkshopID
+	}
+	return fmt.Sprintf("%s (%s)", e.Name, e.WorkshopID)
+}
@@ -0,0 +1,183 @@
+// Package rcon implements a client for the Source RCON protocol,
+// see https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// packet types
+const (
+	typeResponse     int32 = 0 // SERVERDATA_RESPONSE_VALUE
+	typeExec         int32 = 2 // SERVERDATA_EXECCOMMAND
+	typeAuthResponse int32 = 2 // SERVERDATA_AUTH_RESPONSE
+	typeAuth         int32 = 3 // SERVERDATA_AUTH
+)
+
+// maxPacketSize is the maximum size of a packet sent by a server
+const maxPacketSize = 4096 + 10
+
+var (
+	AuthErr   = errors.New("rcon authentication failed")
+	ClosedErr = errors.New("rcon server closed the connection")
+)
+
+// packet is a single RCON packet
+type packet struct {
+	id   int32
+	typ  int32
+	body string
+}
+
+// Client is a connection to an RCON server
+type Client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	id      int32
+}
+
+// Dial connects to the RCON server at address and authenticates with password
+func Dial(address, password string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to rcon: %w", err)
+	}
+
+	c := &Client{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: timeout,
+	}
+	if err := c.auth(password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// auth authenticates the connection, servers may send an empty response before the auth response
+func (c *Client) auth(password string) error {
+	id := c.nextID()
+	if err := c.write(packet{id: id, typ: typeAuth, body: password}); err != nil {
+		return err
+	}
+	for {
+		p, err := c.read()
+		if err != nil {
+			return err
+		}
+		if p.typ != typeAuthResponse {
+			continue
+		}
+		if p.id == -1 || p.id != id {
+			return AuthErr
+		}
+		return nil
+	}
+}
+
+// Exec executes the command and returns the response of the server.
+// If the server closes the connection after the command was sent, the error wraps ClosedErr.
+// Responses split into multiple packets are joined: an empty response packet is sent after the command,
+// the server answers it after the last packet of the command response.
+func (c *Client) Exec(command string) (string, error) {
+	id := c.nextID()
+	if err := c.write(packet{id: id, typ: typeExec, body: command}); err != nil {
+		return "", err
+	}
+	end := c.nextID()
+	if err := c.write(packet{id: end, typ: typeResponse}); err != nil {
+		return "", closed(err)
+	}
+
+	var response strings.Builder
+	for {
+		p, err := c.read()
+		if err != nil {
+			return response.String(), closed(err)
+		}
+		switch {
+		case p.typ != typeResponse:
+			continue
+		case p.id == id:
+			response.WriteString(p.body)
+		case p.id == end:
+			// servers may send a second answer to the empty packet, it is skipped by its outdated id
+			return response.String(), nil
+		}
+	}
+}
+
+// closed wraps errors of a connection the server closed after a command was sent with ClosedErr,
+// commands like quit stop the server without a response
+func closed(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return fmt.Errorf("%w: %v", ClosedErr, err)
+	}
+	return err
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) nextID() int32 {
+	c.id++
+	return c.id
+}
+
+// write sends a packet: size, id, type, null terminated body and an empty string
+func (c *Client) write(p packet) error {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.LittleEndian, int32(len(p.body)+10))
+	_ = binary.Write(&b, binary.LittleEndian, p.id)
+	_ = binary.Write(&b, binary.LittleEndian, p.typ)
+	b.WriteString(p.body)
+	b.Write([]byte{0, 0})
+
+	if c.timeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	if _, err := c.conn.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("failed to send rcon packet: %w", err)
+	}
+	return nil
+}
+
+// read receives a packet
+func (c *Client) read() (packet, error) {
+	if c.timeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+
+	var size int32
+	if err := binary.Read(c.r, binary.LittleEndian, &size); err != nil {
+		return packet{}, fmt.Errorf("failed to read rcon packet: %w", err)
+	}
+	if size < 10 || size > maxPacketSize {
+		return packet{}, fmt.Errorf("invalid rcon packet size %d", size)
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return packet{}, fmt.Errorf("failed to read rcon packet: %w", err)
+	}
+
+	return packet{
+		id:   int32(binary.LittleEndian.Uint32(b[0:4])),
+		typ:  int32(binary.LittleEndian.Uint32(b[4:8])),
+		body: string(bytes.TrimRight(b[8:], "\x00")),
+	}, nil
+}
@@ -0,0 +1,122 @@
+package rcon
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPassword = "secret"
+
+// listen starts a server with the handler and returns its address, the server is closed after the test
+func listen(t *testing.T, handler Handler) (*Server, string) {
+	t.Helper()
+	s := NewServer(testPassword, handler)
+	address, err := s.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s, address
+}
+
+// dial connects to the server at address with the test password
+func dial(t *testing.T, address string) *Client {
+	t.Helper()
+	c, err := Dial(address, testPassword, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
+
+func TestDialAuthFailure(t *testing.T) {
+	_, address := listen(t, func(command string) string { return command })
+
+	c, err := Dial(address, "wrong", 5*time.Second)
+	if !errors.Is(err, AuthErr) {
+		t.Fatalf("Dial() error = %v, want %v", err, AuthErr)
+	}
+	if c != nil {
+		t.Errorf("Dial() client = %v, want nil", c)
+	}
+}
+
+func TestExec(t *testing.T) {
+	long := strings.Repeat("0123456789", 1000)
+	responses := map[string]string{
+		"players": "no players",
+		"long":    long,
+		"exact":   long[:maxBodySize],
+		"empty":   "",
+	}
+	_, address := listen(t, func(command string) string { return responses[command] })
+
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{name: "single packet", command: "players", want: "no players"},
+		{name: "multiple packets", command: "long", want: long},
+		{name: "exactly one full packet", command: "exact", want: long[:maxBodySize]},
+		{name: "empty response", command: "empty", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dial(t, address).Exec(tt.command)
+			if err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Exec() = %d bytes, want %d bytes", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+// the answers to the empty packet marking the end of a response must not end the response of the next command
+func TestExecEndMarker(t *testing.T) {
+	long := strings.Repeat("x", 3*maxBodySize)
+	_, address := listen(t, func(command string) string {
+		if command == "long" {
+			return long
+		}
+		return "response to " + command
+	})
+
+	c := dial(t, address)
+	for _, command := range []string{"first", "long", "", "second"} {
+		want := "response to " + command
+		if command == "long" {
+			want = long
+		}
+		got, err := c.Exec(command)
+		if err != nil {
+			t.Fatalf("Exec(%q) error = %v", command, err)
+		}
+		if got != want {
+			t.Errorf("Exec(%q) = %.40q, want %.40q", command, got, want)
+		}
+	}
+}
+
+func TestExecClosed(t *testing.T) {
+	var s *Server
+	s, address := listen(t, func(command string) string {
+		// the server stops without a response
+		_ = s.Close()
+		return ""
+	})
+
+	_, err := dial(t, address).Exec("quit")
+	if !errors.Is(err, ClosedErr) {
+		t.Fatalf("Exec() error = %v, want %v", err, ClosedErr)
+	}
+}
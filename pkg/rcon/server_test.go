@@ -0,0 +1,141 @@
+package rcon
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+)
+
+// maxBodySize is the maximum body size of a response packet, longer responses are split
+const maxBodySize = 4096
+
+// Handler returns the response to a command
+type Handler func(command string) string
+
+// Server is a minimal RCON server that behaves like a Source dedicated server,
+// it is used to test the client without a game server.
+type Server struct {
+	password string
+	handler  Handler
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+func NewServer(password string, handler Handler) *Server {
+	return &Server{
+		password: password,
+		handler:  handler,
+		conns:    map[net.Conn]struct{}{},
+	}
+}
+
+// Listen listens on address, e.g. 127.0.0.1:0, and serves connections in the background.
+// It returns the address the server listens on.
+func (s *Server) Listen(address string) (string, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	go func() {
+		_ = s.Serve(l)
+	}()
+	return l.Addr().String(), nil
+}
+
+// Serve accepts connections on l until it is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// Close stops listening and closes all connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// serve answers the packets of a connection, commands are only executed after authentication
+func (s *Server) serve(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	c := &Client{conn: conn, r: bufio.NewReader(conn)}
+	authenticated := false
+	for {
+		p, err := c.read()
+		if err != nil {
+			return
+		}
+
+		switch p.typ {
+		case typeAuth:
+			authenticated = p.body == s.password
+			id := p.id
+			if !authenticated {
+				id = -1
+			}
+			if c.write(packet{id: p.id, typ: typeResponse}) != nil || c.write(packet{id: id, typ: typeAuthResponse}) != nil {
+				return
+			}
+		case typeExec:
+			if !authenticated {
+				return
+			}
+			if !s.respond(c, p.id, s.handler(p.body)) {
+				return
+			}
+		case typeResponse:
+			// mirror the packet that marks the end of a multi-packet response
+			if c.write(packet{id: p.id, typ: typeResponse}) != nil ||
+				c.write(packet{id: p.id, typ: typeResponse, body: "\x00\x01\x00\x00"}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// respond sends the response split into packets of maxBodySize
+func (s *Server) respond(c *Client, id int32, response string) bool {
+	for {
+		n := len(response)
+		if n > maxBodySize {
+			n = maxBodySize
+		}
+		if c.write(packet{id: id, typ: typeResponse, body: response[:n]}) != nil {
+			return false
+		}
+		response = response[n:]
+		if response == "" {
+			return true
+		}
+	}
+}
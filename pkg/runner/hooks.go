@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"github.com/Cehir/steam-workshop-downloader/pkg/hooks"
+	"strconv"
+)
+
+// appEnv returns the hook environment of an app
+func appEnv(app *config.App) hooks.Env {
+	return hooks.Env{
+		"APP_ID":      app.AppID,
+		"APP_NAME":    app.Name,
+		"DESTINATION": app.Path,
+	}
+}
+
+// appRCON returns the RCON endpoint of the server of an app, nil if it has none
+func appRCON(app *config.App) *config.RCON {
+	if app.Server == nil {
+		return nil
+	}
+	return app.Server.RCON
+}
+
+// preDownload runs the global and then the app hooks before steamcmd is started
+func preDownload(ctx context.Context, cfg *config.Config) error {
+	if err := hooks.Run(ctx, hooks.PreDownload, hooks.Of(cfg.Hooks, hooks.PreDownload), hooks.Env{}, nil); err != nil {
+		return err
+	}
+	for _, app := range cfg.Apps {
+		if err := hooks.Run(ctx, hooks.PreDownload, hooks.Of(app.Hooks, hooks.PreDownload), appEnv(app), appRCON(app)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postCopy runs the global and then the app hooks after a mod was copied
+func postCopy(ctx context.Context, cfg *config.Config, e event.Event) error {
+	app, mod := cfg.Apps.Find(e.WorkshopID)
+	if app == nil {
+		return nil
+	}
+
+	env := appEnv(app)
+	env["MOD_ID"] = mod.WorkshopID
+	env["MOD_NAME"] = mod.Name
+	env["DESTINATION"] = e.Path
+	env["RESULT"] = string(event.Copied)
+
+	if err := hooks.Run(ctx, hooks.PostCopy, hooks.Of(cfg.Hooks, hooks.PostCopy), env, appRCON(app)); err != nil {
+		return err
+	}
+	return hooks.Run(ctx, hooks.PostCopy, hooks.Of(app.Hooks, hooks.PostCopy), env, appRCON(app))
+}
+
+// postRun runs the app hooks and then the global hooks after the run with the result of the run
+func postRun(ctx context.Context, cfg *config.Config, summary *event.Summary, runErr error) error {
+	for _, app := range cfg.Apps {
+		results := summary.Filter(func(r *event.Result) bool { return r.AppID == app.AppID })
+		env := appEnv(app)
+		for k, v := range resultEnv(results, runErr) {
+			env[k] = v
+		}
+		if err := hooks.Run(ctx, hooks.PostRun, hooks.Of(app.Hooks, hooks.PostRun), env, appRCON(app)); err != nil {
+			return err
+		}
+	}
+	return hooks.Run(ctx, hooks.PostRun, hooks.Of(cfg.Hooks, hooks.PostRun), resultEnv(summary.Results, runErr), nil)
+}
+
+// resultEnv returns the hook environment describing the results
+func resultEnv(results []*event.Result, runErr error) hooks.Env {
+	copied, failed, updated := 0, 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case event.Copied:
+			copied++
+		case event.Failed:
+			failed++
+		}
+		if r.Updated {
+			updated++
+		}
+	}
+
+	result := "success"
+	if failed > 0 || runErr != nil {
+		result = "failure"
+	}
+	return hooks.Env{
+		"RESULT":  result,
+		"COPIED":  strconv.Itoa(copied),
+		"FAILED":  strconv.Itoa(failed),
+		"UPDATED": strconv.Itoa(updated),
+	}
+}
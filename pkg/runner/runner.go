@@ -0,0 +1,462 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/backup"
+	"github.com/Cehir/steam-workshop-downloader/pkg/clean"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"github.com/Cehir/steam-workshop-downloader/pkg/gameserver"
+	"github.com/Cehir/steam-workshop-downloader/pkg/manifest"
+	"github.com/Cehir/steam-workshop-downloader/pkg/notify"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"github.com/Cehir/steam-workshop-downloader/pkg/steamcmd"
+	"github.com/Cehir/steam-workshop-downloader/pkg/verify"
+	"github.com/Cehir/steam-workshop-downloader/pkg/workshop"
+	logger "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+var (
+	BusyErr = errors.New("a download is already running")
+)
+
+// Options select the mods of a run
+type Options struct {
+	OnlyUpdated bool     // download only mods that were updated in the workshop since they were installed
+	AppID       string   // download only mods of this app, all apps if empty
+	WorkshopIDs []string // download only these mods, all mods if empty, they are downloaded even if they are up to date
+	Validate    bool     // let steamcmd validate the files of the mods
+}
+
+// Status is the status of a runner
+type Status struct {
+	Running      bool           `json:"running" yaml:"running"`                 // a run is in progress
+	Runs         int            `json:"runs" yaml:"runs"`                       // number of finished runs
+	LastStarted  time.Time      `json:"last_started" yaml:"last_started"`       // start of the last run
+	LastFinished time.Time      `json:"last_finished" yaml:"last_finished"`     // end of the last run
+	LastError    string         `json:"last_error,omitempty" yaml:"last_error"` // error of the last run
+	NextRun      time.Time      `json:"next_run" yaml:"next_run"`               // next scheduled run
+	Last         *event.Summary `json:"last,omitempty" yaml:"last"`             // summary of the last run
+}
+
+// Runner runs downloads, only one run at a time, and records installed mods in the state
+type Runner struct {
+	mu       sync.Mutex
+	status   Status
+	handlers []event.Handler
+	workshop *workshop.Client
+}
+
+func NewRunner() *Runner {
+	return &Runner{
+		workshop: workshop.NewClient(),
+	}
+}
+
+// OnEvent registers a handler that receives all events of all runs
+func (r *Runner) OnEvent(h event.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, h)
+}
+
+// Status returns the current status of the runner
+func (r *Runner) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// SetNextRun records the time of the next scheduled run
+func (r *Runner) SetNextRun(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.NextRun = t
+}
+
+// start marks the runner as running, it returns false if a run is already in progress
+func (r *Runner) start() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.Running {
+		return false
+	}
+	r.status.Running = true
+	r.status.LastStarted = time.Now()
+	return true
+}
+
+// finish records the result of a run
+func (r *Runner) finish(summary *event.Summary, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Running = false
+	r.status.Runs++
+	r.status.LastFinished = time.Now()
+	if summary != nil {
+		r.status.Last = summary
+	}
+	r.status.LastError = ""
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+}
+
+// Run downloads the mods of cfg selected by opts and records the installed mods in the state.
+// It returns BusyErr if another run is in progress and a summary without items if there was nothing to download.
+// Runs that fail before steamcmd starts, e.g. for lack of disk space, return a summary with the error.
+func (r *Runner) Run(ctx context.Context, cfg *config.Config, opts Options) (*event.Summary, error) {
+	if !r.start() {
+		return nil, BusyErr
+	}
+
+	summary, err := r.run(ctx, cfg, opts)
+	r.finish(summary, err)
+	return summary, err
+}
+
+// Start starts a run like Run in the background, it returns BusyErr if another run is in progress
+func (r *Runner) Start(ctx context.Context, cfg *config.Config, opts Options) error {
+	if !r.start() {
+		return BusyErr
+	}
+
+	go func() {
+		summary, err := r.run(ctx, cfg, opts)
+		r.finish(summary, err)
+		if err != nil {
+			logger.WithError(err).Error("failed to download mods")
+		}
+	}()
+	return nil
+}
+
+func (r *Runner) run(ctx context.Context, cfg *config.Config, opts Options) (*event.Summary, error) {
+	if opts.AppID != "" {
+		cfg = cfg.Filter(func(app *config.App, mod *config.Mod) bool {
+			return app.AppID == opts.AppID
+		})
+		if len(cfg.Apps) == 0 {
+			return nil, fmt.Errorf("app %s has no mods", opts.AppID)
+		}
+	}
+
+	if len(opts.WorkshopIDs) > 0 {
+		ids := make(map[string]bool, len(opts.WorkshopIDs))
+		for _, id := range opts.WorkshopIDs {
+			ids[id] = true
+		}
+		cfg = cfg.Filter(func(app *config.App, mod *config.Mod) bool {
+			return ids[mod.WorkshopID]
+		})
+		if len(cfg.Apps) == 0 {
+			return nil, fmt.Errorf("mods %v are not configured", opts.WorkshopIDs)
+		}
+	}
+
+	st, err := state.Load(cfg.State)
+	if err != nil {
+		return r.skipped(ctx, cfg, err), err
+	}
+
+	// a mirror has no network, its workshop manifests have the versions
+	var details map[string]*workshop.Details
+	latest := withManifests(nil, cfg)
+	if !cfg.Steam.Offline() {
+		details, err = r.workshop.Details(ctx, cfg.Apps.WorkshopIDs())
+		if err != nil {
+			if opts.OnlyUpdated {
+				err = fmt.Errorf("failed to check for updates: %w", err)
+				return r.skipped(ctx, cfg, err), err
+			}
+			logger.WithError(err).Warn("failed to get workshop details, using the workshop manifests of steamcmd")
+		}
+		latest = details
+	}
+
+	if opts.OnlyUpdated {
+		cfg = cfg.Filter(func(app *config.App, mod *config.Mod) bool {
+			d, ok := latest[mod.WorkshopID]
+			if !ok || !d.Exists() {
+				logger.WithField("workshop_id", mod.WorkshopID).Warn("mod not found in workshop")
+				return false
+			}
+			return st.Outdated(mod.WorkshopID, int64(d.TimeUpdated))
+		})
+		if len(cfg.Apps) == 0 {
+			logger.Info("all mods are up to date")
+			return r.skipped(ctx, cfg, nil), nil
+		}
+	}
+
+	cached := withManifests(details, cfg)
+
+	// refuse to start instead of failing halfway with broken installs
+	if err := checkSpace(cfg, cached, st); err != nil {
+		return r.skipped(ctx, cfg, err), err
+	}
+
+	// hooks abort the run by cancelling steamcmd
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := preDownload(runCtx, cfg); err != nil {
+		return r.skipped(ctx, cfg, err), err
+	}
+
+	c := steamcmd.NewSteamCmd(cfg)
+	c.Validate(opts.Validate)
+	if cfg.Steam.Source == config.SourceCache {
+		// cached mods older than the workshop are downloaded with steamcmd
+		versions := make(map[string]int64, len(details))
+		for id, d := range details {
+			versions[id] = int64(d.TimeUpdated)
+		}
+		c.Versions(versions)
+	}
+	var serverErr error
+	for _, app := range cfg.Apps {
+		if app.Server == nil {
+			continue
+		}
+		controller, err := gameserver.NewController(app)
+		if err != nil {
+			err = fmt.Errorf("invalid server of app %s: %w", app, err)
+			return r.skipped(ctx, cfg, err), err
+		}
+		app := app
+		c.DeferInstall(app.AppID, func(install func() error) error {
+			// servers are only restarted if their mods changed, installing the same files again does not need it
+			if !changed(app, withManifests(details, cfg), st) && intact(cfg, app) {
+				logger.WithField("app_id", app.AppID).Debug("no mods changed, installing without restarting the server")
+				return install()
+			}
+			err := controller.Restart(runCtx, install)
+			if err != nil && serverErr == nil {
+				serverErr = fmt.Errorf("failed to update server of app %s: %w", controller, err)
+			}
+			return err
+		})
+	}
+
+	// snapshots are taken right before the first mod of an app is installed, with a server it is already stopped.
+	// Runs installing the same versions again take no snapshot to keep the retained snapshots useful.
+	for _, app := range cfg.Apps {
+		if app.Backup == nil {
+			continue
+		}
+		app := app
+		c.BeforeInstall(app.AppID, func() error {
+			if !changed(app, withManifests(details, cfg), st) {
+				logger.WithField("app_id", app.AppID).Debug("no mods changed, taking no snapshot")
+				return nil
+			}
+			if _, err := backup.Take(app, st); err != nil {
+				return fmt.Errorf("failed to back up app %s: %w", app, err)
+			}
+			return nil
+		})
+	}
+
+	var hookErr error
+	c.OnEvent(func(e event.Event) {
+		if e.Type != event.ItemCopied || hookErr != nil {
+			return
+		}
+		if err := postCopy(runCtx, cfg, e); err != nil {
+			hookErr = err
+			cancel()
+		}
+	})
+	// annotate the summary before other handlers receive the end of the run,
+	// mods without workshop details get the version steamcmd just installed
+	installed := details
+	c.OnEvent(func(e event.Event) {
+		if e.Type == event.RunFinished && e.Summary != nil {
+			installed = withManifests(details, cfg)
+			annotate(e.Summary, installed, st)
+		}
+	})
+	r.mu.Lock()
+	for _, h := range r.handlers {
+		c.OnEvent(h)
+	}
+	r.mu.Unlock()
+
+	summary, err := c.DownloadContext(runCtx)
+	if summary == nil {
+		return nil, err
+	}
+	if serverErr != nil && err == nil {
+		err = serverErr
+		summary.Error = serverErr.Error()
+	}
+	if hookErr != nil {
+		err = hookErr
+		summary.Error = hookErr.Error()
+	}
+
+	// record the installed version of every copied mod
+	for _, result := range summary.Results {
+		if result.Status != event.Copied {
+			continue
+		}
+		item := &state.Item{
+			AppID:      result.AppID,
+			WorkshopID: result.WorkshopID,
+			Installed:  summary.Finished,
+			Path:       result.Path,
+			Bytes:      result.Bytes,
+		}
+		if d, ok := installed[result.WorkshopID]; ok {
+			item.Title = d.Title
+			item.TimeUpdated = int64(d.TimeUpdated)
+		}
+		st.Set(item)
+	}
+	if saveErr := st.Save(); saveErr != nil {
+		logger.WithError(saveErr).Error("failed to save state")
+	}
+
+	// mirrors are kept, they are usually synced from another host
+	if cfg.Steam.Clean && !cfg.Steam.Offline() {
+		cleanCache(cfg, summary)
+	}
+
+	if hookErr := postRun(ctx, cfg, summary, err); hookErr != nil && err == nil {
+		err = hookErr
+	}
+
+	if notifyErr := notify.Send(ctx, cfg.Notifications, summary); notifyErr != nil {
+		logger.WithError(notifyErr).Error("failed to notify")
+	}
+
+	return summary, err
+}
+
+// skipped returns the summary of a run that did not start steamcmd, because its mods are up to date
+// or because err stopped it before. Handlers and notifications receive it like any other run.
+func (r *Runner) skipped(ctx context.Context, cfg *config.Config, err error) *event.Summary {
+	summary := &event.Summary{}
+	r.mu.Lock()
+	handlers := append([]event.Handler{summary.Handle}, r.handlers...)
+	r.mu.Unlock()
+
+	now := time.Now()
+	finished := event.Event{Type: event.RunFinished, Time: now, Summary: summary}
+	if err != nil {
+		finished.Error = err.Error()
+		summary.ExitCode = -1
+	}
+	for _, e := range []event.Event{
+		{Type: event.RunStarted, Time: now, Schema: event.SchemaVersion, Items: cfg.Apps.Count()},
+		finished,
+	} {
+		for _, h := range handlers {
+			h(e)
+		}
+	}
+
+	if err := notify.Send(ctx, cfg.Notifications, summary); err != nil {
+		logger.WithError(err).Error("failed to notify")
+	}
+	return summary
+}
+
+// cleanCache removes the copied mods from the workshop cache, errors are only logged
+func cleanCache(cfg *config.Config, summary *event.Summary) {
+	var ids []string
+	for _, result := range summary.Filter(func(r *event.Result) bool { return r.Status == event.Copied }) {
+		ids = append(ids, result.WorkshopID)
+	}
+	entries, err := clean.Results(cfg, ids)
+	if err != nil {
+		logger.WithError(err).Error("failed to clean workshop cache")
+		return
+	}
+	reclaimed, err := clean.Remove(&cfg.Steam, entries)
+	if err != nil {
+		logger.WithError(err).Error("failed to clean workshop cache")
+	}
+	logger.WithField("mods", len(entries)).WithField("reclaimed", output.Bytes(reclaimed)).Info("cleaned workshop cache")
+}
+
+// withManifests returns the details completed by the versions in the workshop manifests of steamcmd
+// for mods without details, e.g. if the workshop could not be reached
+func withManifests(details map[string]*workshop.Details, cfg *config.Config) map[string]*workshop.Details {
+	merged := make(map[string]*workshop.Details, len(details))
+	for id, d := range details {
+		merged[id] = d
+	}
+	for _, app := range cfg.Apps {
+		m, err := manifest.Load(cfg.Steam.ManifestPath(app.AppID))
+		if err != nil {
+			logger.WithError(err).WithField("app_id", app.AppID).Warn("failed to read workshop manifest")
+			continue
+		}
+		for _, mod := range app.Mods {
+			item := m.Get(mod.WorkshopID)
+			if _, ok := merged[mod.WorkshopID]; ok || item == nil || item.TimeUpdated == 0 {
+				continue
+			}
+			merged[mod.WorkshopID] = &workshop.Details{
+				WorkshopID:  mod.WorkshopID,
+				Result:      workshop.ResultOK,
+				FileSize:    workshop.Number(item.Size),
+				TimeUpdated: workshop.Number(item.TimeUpdated),
+			}
+		}
+	}
+	return merged
+}
+
+// changed returns true if a mod of the app is not installed or installed in another version than the latest,
+// mods without a known latest version are ignored
+func changed(app *config.App, details map[string]*workshop.Details, st *state.State) bool {
+	for _, mod := range app.Mods {
+		d, ok := details[mod.WorkshopID]
+		if !ok {
+			continue
+		}
+		if item := st.Get(mod.WorkshopID); item == nil || item.TimeUpdated != int64(d.TimeUpdated) {
+			return true
+		}
+	}
+	return false
+}
+
+// intact returns true if the installed files of all mods of the app equal the files in the workshop content directory
+func intact(cfg *config.Config, app *config.App) bool {
+	for _, r := range verify.Apps(&cfg.Steam, config.Apps{app}) {
+		if r.Error != "" || len(r.Missing) > 0 || len(r.Modified) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// annotate sets the workshop title of every result and marks mods installed in a new version as updated
+func annotate(summary *event.Summary, details map[string]*workshop.Details, st *state.State) {
+	for _, result := range summary.Results {
+		d, ok := details[result.WorkshopID]
+		if ok {
+			result.Title = d.Title
+		}
+		if result.Status != event.Copied {
+			continue
+		}
+		if ok {
+			result.Updated = st.Outdated(result.WorkshopID, int64(d.TimeUpdated))
+		} else {
+			result.Updated = st.Get(result.WorkshopID) == nil
+		}
+		if result.Updated {
+			summary.Updated++
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"errors"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/space"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"github.com/Cehir/steam-workshop-downloader/pkg/workshop"
+	logger "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// checkSpace compares the space the download needs with the free space of every volume it writes to.
+// It logs every volume and returns space.NoSpaceErr if one has not enough space, unless cfg.Space is warn.
+func checkSpace(cfg *config.Config, details map[string]*workshop.Details, st *state.State) error {
+	if cfg.Space == config.SpaceOff {
+		return nil
+	}
+	estimate, err := space.New(cfg, details, st)
+	if errors.Is(err, path.NotSupportedErr) {
+		logger.WithError(err).Debug("free disk space is unknown, skipping the disk space check")
+		return nil
+	}
+	if err != nil {
+		logger.WithError(err).Warn("failed to check disk space")
+		return nil
+	}
+
+	for _, v := range estimate.Volumes {
+		l := logger.WithFields(logger.Fields{
+			"path":     v.Path,
+			"uses":     strings.Join(v.Uses, ", "),
+			"required": output.Bytes(v.Required),
+			"free":     output.Bytes(v.Free),
+		})
+		if v.Enough() {
+			l.Debug("enough disk space")
+		} else {
+			l.Warn("not enough disk space")
+		}
+	}
+	if len(estimate.Unknown) > 0 {
+		logger.WithField("workshop_ids", estimate.Unknown).Info("size of mods unknown, they are not included in the disk space check")
+	}
+
+	if cfg.Space == config.SpaceWarn {
+		return nil
+	}
+	return estimate.ShortErr()
+}
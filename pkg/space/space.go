@@ -0,0 +1,176 @@
+package space
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/output"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"github.com/Cehir/steam-workshop-downloader/pkg/workshop"
+	"os"
+	"strings"
+)
+
+var (
+	NoSpaceErr = errors.New("not enough disk space")
+)
+
+// Cache is the use of the volume of the workshop content directory
+const Cache = "workshop cache"
+
+// Volume is the space a download needs on a volume
+type Volume struct {
+	Path     string   `json:"path"`     // first path of the download on the volume
+	Uses     []string `json:"uses"`     // what the download writes to the volume, the workshop cache or apps
+	Required int64    `json:"required"` // estimated bytes the download needs
+	Free     int64    `json:"free"`     // bytes available
+}
+
+// Enough returns true if the volume has the space the download needs
+func (v *Volume) Enough() bool {
+	return v.Required <= v.Free
+}
+
+func (v *Volume) String() string {
+	return fmt.Sprintf("%s (%s): %s required, %s free", v.Path, strings.Join(v.Uses, ", "),
+		output.Bytes(v.Required), output.Bytes(v.Free))
+}
+
+// Estimate is the space a download needs on each volume it writes to
+type Estimate struct {
+	Volumes []*Volume `json:"volumes"`           // volumes in the order they were first used
+	Unknown []string  `json:"unknown,omitempty"` // workshop ids of mods of unknown size, they are not included
+	ids     map[uint64]*Volume
+}
+
+// Short returns the volumes without the space the download needs
+func (e *Estimate) Short() []*Volume {
+	var short []*Volume
+	for _, v := range e.Volumes {
+		if !v.Enough() {
+			short = append(short, v)
+		}
+	}
+	return short
+}
+
+// ShortErr returns an error listing the volumes without the space the download needs, nil if all have enough
+func (e *Estimate) ShortErr() error {
+	short := e.Short()
+	if len(short) == 0 {
+		return nil
+	}
+	s := make([]string, len(short))
+	for i, v := range short {
+		s[i] = v.String()
+	}
+	return fmt.Errorf("%w: %s", NoSpaceErr, strings.Join(s, "; "))
+}
+
+// New estimates the space downloading the mods of cfg needs in the workshop content directory and in the app paths.
+// The size of a mod is its file size in details, or the bytes it had when it was installed according to st.
+// Mods already in the content directory or installed only need the space they grow by, files are replaced one by one.
+// Apps installed as symlinks, or as hardlinks or reflinks on the volume of the content directory, need no space.
+// It returns path.NotSupportedErr on platforms without free space information.
+func New(cfg *config.Config, details map[string]*workshop.Details, st *state.State) (*Estimate, error) {
+	e := &Estimate{ids: map[uint64]*Volume{}}
+	content := cfg.Steam.ContentDir()
+	source, err := path.Volume(content)
+	if err != nil {
+		return nil, err
+	}
+	// a mirror is only read
+	cache := !cfg.Steam.Offline()
+
+	var cached int64
+	for _, app := range cfg.Apps {
+		dst, err := path.Volume(app.Path)
+		if err != nil {
+			return nil, err
+		}
+		var required int64
+		for _, mod := range app.Mods {
+			n, ok := size(mod.WorkshopID, details, st)
+			if !ok {
+				e.Unknown = append(e.Unknown, mod.WorkshopID)
+				continue
+			}
+			if cache {
+				cached += grows(n, dirSize(cfg.Steam.ModDir(app.AppID, mod.WorkshopID)))
+			}
+			if item := st.Get(mod.WorkshopID); item != nil {
+				required += grows(n, item.Bytes)
+			} else {
+				required += n
+			}
+		}
+
+		switch path.Mode(app.Install) {
+		case path.Symlink:
+			required = 0
+		case path.Hardlink, path.Reflink:
+			if dst == source {
+				required = 0
+			}
+		}
+		if err := e.add(dst, app.Path, app.String(), required); err != nil {
+			return nil, err
+		}
+	}
+
+	if cache {
+		if err := e.add(source, content, Cache, cached); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// add adds the bytes a use needs to the volume with the id, dir is a path of the use on the volume
+func (e *Estimate) add(id uint64, dir, use string, bytes int64) error {
+	v, ok := e.ids[id]
+	if !ok {
+		free, err := path.Free(dir)
+		if err != nil {
+			return err
+		}
+		v = &Volume{Path: dir, Free: free}
+		e.ids[id] = v
+		e.Volumes = append(e.Volumes, v)
+	}
+	v.Uses = append(v.Uses, use)
+	v.Required += bytes
+	return nil
+}
+
+// size returns the size of a mod from its workshop details or from the state, false if it is unknown
+func size(workshopID string, details map[string]*workshop.Details, st *state.State) (int64, bool) {
+	if d, ok := details[workshopID]; ok && d.FileSize > 0 {
+		return int64(d.FileSize), true
+	}
+	if item := st.Get(workshopID); item != nil && item.Bytes > 0 {
+		return item.Bytes, true
+	}
+	return 0, false
+}
+
+// grows returns the bytes a mod of size n needs over the bytes it already has
+func grows(n, has int64) int64 {
+	if n <= has {
+		return 0
+	}
+	return n - has
+}
+
+// dirSize returns the size of dir, 0 if it does not exist
+func dirSize(dir string) int64 {
+	if _, err := os.Stat(dir); err != nil {
+		return 0
+	}
+	n, err := path.Size(dir)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,114 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State records which version of each mod is installed, it is stored as JSON file
+type State struct {
+	mu    sync.Mutex
+	path  string
+	Items map[string]*Item `json:"items"` // installed items by workshop id
+}
+
+// Item is an installed workshop item
+type Item struct {
+	AppID       string    `json:"app_id"`                 // Steam App ID
+	WorkshopID  string    `json:"workshop_id"`            // Steam Workshop ID
+	Title       string    `json:"title,omitempty"`        // workshop title
+	TimeUpdated int64     `json:"time_updated,omitempty"` // workshop unix time of the installed version, 0 if unknown
+	Installed   time.Time `json:"installed"`              // time the item was copied to its destination
+	Path        string    `json:"path,omitempty"`         // destination of the item
+	Bytes       int64     `json:"bytes,omitempty"`        // bytes copied to the destination
+}
+
+// Load reads the state from path, a missing file results in an empty state.
+// An empty path results in a state that is not saved.
+func Load(path string) (*State, error) {
+	s := &State{
+		path:  path,
+		Items: make(map[string]*Item),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	if s.Items == nil {
+		s.Items = make(map[string]*Item)
+	}
+	return s, nil
+}
+
+// Save writes the state to its file, the file is replaced atomically
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// Get returns the installed item of the given workshop id, nil if it is not installed
+func (s *State) Get(workshopID string) *Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Items[workshopID]
+}
+
+// Set records the given item as installed
+func (s *State) Set(item *Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Items[item.WorkshopID] = item
+}
+
+// Delete removes the installed item of the given workshop id
+func (s *State) Delete(workshopID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Items, workshopID)
+}
+
+// Outdated returns true if the item is not installed or the workshop was updated after the installed version
+func (s *State) Outdated(workshopID string, timeUpdated int64) bool {
+	item := s.Get(workshopID)
+	if item == nil || item.TimeUpdated == 0 {
+		return true
+	}
+	return timeUpdated > item.TimeUpdated
+}
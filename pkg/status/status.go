@@ -0,0 +1,101 @@
+package status
+
+import (
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/manifest"
+	"github.com/Cehir/steam-workshop-downloader/pkg/state"
+	"github.com/Cehir/steam-workshop-downloader/pkg/workshop"
+	logger "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sources of the local version of a mod
+const (
+	SourceState    = "state" // the state of the downloader
+	SourceManifest = "acf"   // the workshop manifest of steamcmd
+)
+
+// Mod is the status of a configured mod
+type Mod struct {
+	AppID         string     `json:"app_id" yaml:"app_id"`                                         // Steam App ID
+	WorkshopID    string     `json:"workshop_id" yaml:"workshop_id"`                               // Steam Workshop ID
+	Name          string     `json:"name,omitempty" yaml:"name,omitempty"`                         // configured name of the mod
+	Title         string     `json:"title,omitempty" yaml:"title,omitempty"`                       // workshop title
+	Path          string     `json:"path" yaml:"path"`                                             // destination of the app
+	Installed     bool       `json:"installed" yaml:"installed"`                                   // the mod is installed at the destination
+	LocalUpdated  *time.Time `json:"local_updated,omitempty" yaml:"local_updated,omitempty"`       // workshop time of the installed version
+	LocalSource   string     `json:"local_source,omitempty" yaml:"local_source,omitempty"`         // source of the local version, state or acf
+	Bytes         int64      `json:"bytes,omitempty" yaml:"bytes,omitempty"`                       // size of the installed version
+	RemoteUpdated *time.Time `json:"workshop_updated,omitempty" yaml:"workshop_updated,omitempty"` // workshop time of the latest version
+	OutOfDate     bool       `json:"out_of_date" yaml:"out_of_date"`                               // a newer version is in the workshop or the mod is not installed
+}
+
+// Collect returns the status of all mods of cfg.
+// details may be nil if the workshop could not be reached, mods are then only out of date if they are not installed.
+func Collect(cfg *config.Config, st *state.State, details map[string]*workshop.Details) []*Mod {
+	var mods []*Mod
+	for _, app := range cfg.Apps {
+		m, err := manifest.Load(cfg.Steam.ManifestPath(app.AppID))
+		if err != nil {
+			logger.WithError(err).WithField("app_id", app.AppID).Warn("failed to read workshop manifest")
+		}
+
+		for _, mod := range app.Mods {
+			s := &Mod{
+				AppID:      app.AppID,
+				WorkshopID: mod.WorkshopID,
+				Name:       mod.Name,
+				Path:       app.Path,
+			}
+
+			item := st.Get(mod.WorkshopID)
+			acf := m.Get(mod.WorkshopID)
+			switch {
+			case item != nil && item.TimeUpdated > 0:
+				s.LocalUpdated = unix(item.TimeUpdated)
+				s.LocalSource = SourceState
+				s.Bytes = item.Bytes
+				s.Title = item.Title
+			case acf != nil && acf.TimeUpdated > 0:
+				s.LocalUpdated = unix(acf.TimeUpdated)
+				s.LocalSource = SourceManifest
+				s.Bytes = acf.Size
+			}
+			s.Installed = installed(cfg.Steam.ModDir(app.AppID, mod.WorkshopID), app.Path, item != nil)
+
+			if d, ok := details[mod.WorkshopID]; ok && d.Exists() {
+				s.Title = d.Title
+				s.RemoteUpdated = unix(int64(d.TimeUpdated))
+			}
+			s.OutOfDate = !s.Installed || s.RemoteUpdated != nil && (s.LocalUpdated == nil || s.RemoteUpdated.After(*s.LocalUpdated))
+			mods = append(mods, s)
+		}
+	}
+	return mods
+}
+
+// unix returns the unix time as time, nil if it is 0
+func unix(t int64) *time.Time {
+	if t == 0 {
+		return nil
+	}
+	u := time.Unix(t, 0)
+	return &u
+}
+
+// installed returns true if all top level folders of the mod in the workshop cache exist in the destination.
+// Without the mod in the workshop cache the state decides.
+func installed(modDir, dst string, inState bool) bool {
+	entries, err := os.ReadDir(filepath.Join(modDir, "mods"))
+	if err != nil || len(entries) == 0 {
+		return inState
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(dst, entry.Name())); err != nil {
+			return false
+		}
+	}
+	return true
+}
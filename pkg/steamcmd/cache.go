@@ -0,0 +1,71 @@
+package steamcmd
+
+import (
+	"context"
+	"errors"
+	"github.com/Cehir/steam-workshop-downloader/pkg/cache"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Versions sets the latest known workshop versions by workshop id, cached items older than them are fetched with steamcmd
+func (s *SteamCmd) Versions(versions map[string]int64) {
+	s.versions = versions
+}
+
+// fromCache fetches the mods from the cache server to the workshop content directory and installs them.
+// Only hits are reported, it returns the config of the mods that steamcmd has to download.
+func (s *SteamCmd) fromCache(ctx context.Context) *config.Config {
+	client := cache.NewClient(s.cfg.Steam.Cache.URL, s.cfg.Steam.Cache.Token)
+	hits := map[string]bool{}
+	for _, app := range s.cfg.Apps {
+		for _, mod := range app.Mods {
+			if ctx.Err() != nil {
+				break
+			}
+			log := logger.WithField("workshop_id", mod.WorkshopID)
+
+			item, err := client.Item(ctx, app.AppID, mod.WorkshopID)
+			if errors.Is(err, cache.MissErr) {
+				log.Debug("mod is not in the cache")
+				continue
+			}
+			if err != nil {
+				log.WithError(err).Warn("failed to request mod from cache, downloading with steamcmd")
+				continue
+			}
+			if latest := s.versions[mod.WorkshopID]; latest > item.TimeUpdated {
+				log.WithField("cached", item.TimeUpdated).WithField("latest", latest).Debug("cached mod is outdated")
+				continue
+			}
+
+			s.emit(s.item(event.ItemStarted, mod.WorkshopID))
+			folder := s.cfg.Steam.ModDir(app.AppID, mod.WorkshopID)
+			err = client.Fetch(ctx, item, folder, func(done, total int64) {
+				e := s.item(event.ItemProgress, mod.WorkshopID)
+				e.Done = done
+				e.Total = total
+				s.emit(e)
+			})
+			if err != nil {
+				log.WithError(err).Warn("failed to fetch mod from cache, downloading with steamcmd")
+				continue
+			}
+			hits[mod.WorkshopID] = true
+
+			downloaded := s.item(event.ItemDownloaded, mod.WorkshopID)
+			downloaded.Path = folder
+			downloaded.Total = item.Bytes
+			downloaded.Done = item.Bytes
+			s.emit(downloaded)
+
+			s.downloaded(app.AppID, folder, app.Path)
+		}
+	}
+	logger.WithField("hits", len(hits)).WithField("cache", s.cfg.Steam.Cache.URL).Debug("fetched mods from cache")
+
+	return s.cfg.Filter(func(app *config.App, mod *config.Mod) bool {
+		return !hits[mod.WorkshopID]
+	})
+}
@@ -0,0 +1,45 @@
+package steamcmd
+
+import (
+	"time"
+)
+
+// timeout is the time steamcmd may run, installing the mods it downloaded does not count
+const timeout = 5 * time.Minute
+
+// clock calls a function once steamcmd ran for a duration, it is paused while downloaded mods are installed.
+// It is not safe for concurrent use.
+type clock struct {
+	timer     *time.Timer
+	remaining time.Duration
+	started   time.Time
+	paused    bool
+}
+
+func newClock(d time.Duration, fn func()) *clock {
+	return &clock{timer: time.AfterFunc(d, fn), remaining: d, started: time.Now()}
+}
+
+// pause stops the clock unless it already expired
+func (c *clock) pause() {
+	if c.paused || !c.timer.Stop() {
+		return
+	}
+	c.remaining -= time.Since(c.started)
+	c.paused = true
+}
+
+// resume continues a paused clock
+func (c *clock) resume() {
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	c.started = time.Now()
+	c.timer.Reset(c.remaining)
+}
+
+// stop stops the clock for good
+func (c *clock) stop() {
+	c.timer.Stop()
+}
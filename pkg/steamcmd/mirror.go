@@ -0,0 +1,43 @@
+package steamcmd
+
+import (
+	"context"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
+	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	logger "github.com/sirupsen/logrus"
+)
+
+// fromMirror copies the mods from the local mirror instead of running steamcmd.
+// Mods are reported like downloads of steamcmd, mods missing in the mirror fail.
+func (s *SteamCmd) fromMirror(ctx context.Context) error {
+	logger.WithField("mirror", s.cfg.Steam.ContentDir()).Debug("copying mods from mirror")
+
+	for _, app := range s.cfg.Apps {
+		for _, mod := range app.Mods {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			s.emit(s.item(event.ItemStarted, mod.WorkshopID))
+			folder := s.cfg.Steam.ModDir(app.AppID, mod.WorkshopID)
+			size, err := path.Size(folder)
+			if err != nil {
+				failed := s.item(event.ItemFailed, mod.WorkshopID)
+				failed.Path = folder
+				failed.Error = "not in mirror: " + err.Error()
+				s.emit(failed)
+				continue
+			}
+
+			downloaded := s.item(event.ItemDownloaded, mod.WorkshopID)
+			downloaded.Path = folder
+			downloaded.Total = size
+			downloaded.Done = size
+			s.emit(downloaded)
+
+			s.downloaded(app.AppID, folder, app.Path)
+		}
+	}
+
+	return nil
+}
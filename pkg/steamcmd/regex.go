@@ -0,0 +1,33 @@
+package steamcmd
+
+import "regexp"
+
+// downloadingRegex extracts the workshop id of the item steamcmd starts to download
+// example: Downloading item 2169435993 ...
+// will return 2169435993
+var downloadingRegex = regexp.MustCompile(`Downloading item (\d+)`)
+
+// progressRegex extracts the downloaded and total bytes of the current download
+// example: Update state (0x61) downloading, progress: 45.12 (1234 / 5678)
+// will return 1234 and 5678
+var progressRegex = regexp.MustCompile(`Update state \(0x[0-9a-fA-F]+\) [^,]*, progress: [\d.]+ \((\d+) / (\d+)\)`)
+
+// failedRegex extracts the workshop id and the reason of a failed download
+// example: ERROR! Download item 2169435993 failed (Failure).
+// will return 2169435993 and Failure
+var failedRegex = regexp.MustCompile(`ERROR! Download item (\d+) failed \((.*)\)`)
+
+// downloadedBytesRegex extracts the size of a downloaded item
+// example: Downloaded item 2169435993 to "/home/some_user/Steam/steamapps/workshop/content/108600/2169435993" (31729 bytes)
+// will return 31729
+var downloadedBytesRegex = regexp.MustCompile(`Downloaded item \d+ to ".+" \((\d+) bytes\)`)
+
+// loginRegex extracts the user and the result of a login
+// example: Logging in user 'anonymous' to Steam Public...OK
+// will return anonymous and OK
+var loginRegex = regexp.MustCompile(`Logging in user '([^']*)' to Steam Public\.\.\.(.*)`)
+
+// loginFailedRegex extracts the reason of a failed login
+// example: FAILED (Invalid Password)
+// will return Invalid Password
+var loginFailedRegex = regexp.MustCompile(`FAILED[^(]*\((.+)\)`)
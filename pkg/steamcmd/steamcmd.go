@@ -3,34 +3,133 @@ package steamcmd
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"github.com/Cehir/steam-workshop-downloader/pkg/event"
 	"github.com/Cehir/steam-workshop-downloader/pkg/path"
+	"github.com/Cehir/steam-workshop-downloader/pkg/verify"
 	logger "github.com/sirupsen/logrus"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type SteamCmd struct {
-	cfg *config.Config
+	cfg      *config.Config
+	handlers []event.Handler
+	deferred map[string]func(install func() error) error // install functions of deferred apps by app id
+	pending  map[string][]string                         // download folders of deferred apps by app id
+	before   map[string]func() error                     // run before the first item of an app is installed by app id
+	prepared map[string]error                            // results of the before functions of the current run by app id
+	validate bool                                        // steamcmd validates the files of the mods
+	versions map[string]int64                            // latest known workshop versions by workshop id
 }
 
 func NewSteamCmd(cfg *config.Config) *SteamCmd {
 	return &SteamCmd{
-		cfg: cfg,
+		cfg:      cfg,
+		deferred: make(map[string]func(install func() error) error),
+		pending:  make(map[string][]string),
+		before:   make(map[string]func() error),
+		prepared: make(map[string]error),
 	}
 }
 
-func (s *SteamCmd) Download() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+// OnEvent registers a handler that receives all events of a download run
+func (s *SteamCmd) OnEvent(h event.Handler) {
+	s.handlers = append(s.handlers, h)
+}
+
+// Validate lets steamcmd validate the files of already downloaded mods and download them again if they differ
+func (s *SteamCmd) Validate(validate bool) {
+	s.validate = validate
+}
+
+// emit sends the event to all registered handlers
+func (s *SteamCmd) emit(e event.Event) {
+	e.Time = time.Now()
+	for _, h := range s.handlers {
+		h(e)
+	}
+}
+
+// item returns an event for the given workshop id filled with the configured app and mod
+func (s *SteamCmd) item(t event.Type, workshopID string) event.Event {
+	e := event.Event{Type: t, WorkshopID: workshopID}
+	if app, mod := s.cfg.Apps.Find(workshopID); app != nil {
+		e.AppID = app.AppID
+		e.Name = mod.Name
+	}
+	return e
+}
+
+// Download downloads all configured mods, copies them to their destination and
+// returns a summary of the run. The summary is also returned if steamcmd failed.
+func (s *SteamCmd) Download() (*event.Summary, error) {
+	return s.DownloadContext(context.Background())
+}
+
+// DownloadContext is like Download, steamcmd is killed when ctx is done or it ran for 5 minutes
+func (s *SteamCmd) DownloadContext(ctx context.Context) (*event.Summary, error) {
+	summary := &event.Summary{}
+	s.pending = make(map[string][]string)
+	s.prepared = make(map[string]error)
+	handlers := s.handlers
+	s.handlers = append([]event.Handler{summary.Handle}, handlers...)
+	defer func() {
+		s.handlers = handlers
+	}()
+
+	s.emit(event.Event{Type: event.RunStarted, Schema: event.SchemaVersion, Items: s.cfg.Apps.Count()})
+
+	if s.cfg.Steam.Offline() {
+		err := s.fromMirror(ctx)
+		if err == nil {
+			s.installDeferred()
+		}
+		s.finish(summary, err)
+		return summary, err
+	}
+
+	// steamcmd only downloads the mods the cache does not have
+	apps := s.cfg.Apps
+	if s.cfg.Steam.Source == config.SourceCache {
+		apps = s.fromCache(ctx).Apps
+		if err := ctx.Err(); err != nil {
+			s.finish(summary, err)
+			return summary, err
+		}
+		if len(apps) == 0 {
+			s.installDeferred()
+			s.finish(summary, nil)
+			return summary, nil
+		}
+	}
+
+	// the timeout only limits steamcmd, not installing the mods, backups and server restarts
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	var expired atomic.Bool
+	steamClock := newClock(timeout, func() {
+		expired.Store(true)
+		cancel()
+	})
+	defer steamClock.stop()
 
 	var cmdArgs []string
 	// set login credentials
 	cmdArgs = append(cmdArgs, s.cfg.Steam.Login.CmdArgs()...)
-	// add +workshop_download_item <appid> <modid> <install dir> <validate>
-	cmdArgs = append(cmdArgs, s.cfg.Apps.CmdArgs()...)
+	// add +workshop_download_item <appid> <modid> <validate>
+	if s.validate {
+		cmdArgs = append(cmdArgs, apps.ValidateCmdArgs()...)
+	} else {
+		cmdArgs = append(cmdArgs, apps.CmdArgs()...)
+	}
 	// quit after login
 	cmdArgs = append(cmdArgs, "+quit")
 
@@ -44,43 +143,24 @@ func (s *SteamCmd) Download() error {
 	// init scanner
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to get stdout pipe: %w", err)
+		err = fmt.Errorf("failed to get stdout pipe: %w", err)
+		s.finish(summary, err)
+		return summary, err
 	}
 	scanner := bufio.NewScanner(stdout)
 	scanner.Split(bufio.ScanLines)
 
-	appDestination := s.cfg.Apps.Destinations()
-
-	// start scanner
-	go func() {
-		for scanner.Scan() {
-			text := scanner.Text()
-			logger.Debug(text)
-			if downloadFolder := extractPathRegex.FindStringSubmatch(text); downloadFolder != nil {
-				// extract workshop id from path
-				if appID := appIDRegex.FindStringSubmatch(downloadFolder[1]); appID != nil {
-					f := filepath.Join(downloadFolder[1], "mods")
-					err := path.CopyDir(f, appDestination[appID[1]])
-
-					if err != nil {
-						logger.WithError(err).
-							WithField("workshop_id", appID[1]).
-							WithField("source", f).
-							WithField("destination", appDestination[appID[1]]).
-							Error("failed to copy mod")
-						return
-					}
-				}
-			}
-		}
-	}()
-
 	// start steamcmd
 	if err := cmd.Start(); err != nil {
 		logger.WithError(err).Error("failed to run steamcmd")
+		s.installDeferred()
+		s.finish(summary, err)
+		return summary, err
 	}
 
+	// start scanner, all output has to be read before waiting for steamcmd
 	go func() {
+		s.scan(scanner, steamClock)
 		done <- cmd.Wait()
 	}()
 
@@ -88,11 +168,259 @@ func (s *SteamCmd) Download() error {
 	select {
 	case <-ctx.Done():
 		// kill steamcmd if context is done
-		if err := cmd.Process.Kill(); err != nil {
+		if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
 			logger.WithError(err).Error("failed to kill steamcmd")
 		}
-		return ctx.Err()
+		// wait for the scanner to handle the remaining output
+		<-done
+		err := ctx.Err()
+		if expired.Load() {
+			err = fmt.Errorf("steamcmd did not finish within %s: %w", timeout, context.DeadlineExceeded)
+		}
+		s.finish(summary, err)
+		return summary, err
 	case err := <-done:
+		s.installDeferred()
+		s.finish(summary, err)
+		return summary, err
+	}
+}
+
+// finish marks all mods without a result as failed and emits the end of the run
+func (s *SteamCmd) finish(summary *event.Summary, err error) {
+	for _, app := range s.cfg.Apps {
+		for _, mod := range app.Mods {
+			failed := s.item(event.ItemFailed, mod.WorkshopID)
+			switch r := summary.Result(mod.WorkshopID); {
+			case r == nil:
+				failed.Error = "not downloaded by steamcmd"
+			case r.Status == event.Downloaded:
+				failed.Error = "not copied to destination"
+			default:
+				continue
+			}
+			s.emit(failed)
+		}
+	}
+
+	finished := event.Event{Type: event.RunFinished, Summary: summary}
+	if err != nil {
+		finished.Error = err.Error()
+		summary.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			summary.ExitCode = exitErr.ExitCode()
+		}
+	}
+	s.emit(finished)
+}
+
+// login emits the login of user with the result printed by steamcmd
+func (s *SteamCmd) login(user, result string) {
+	e := event.Event{Type: event.Login, User: user}
+	if !strings.HasPrefix(result, "OK") {
+		e.Error = result
+	}
+	s.emit(e)
+}
+
+// scan reads the steamcmd output, emits events and copies downloaded items to their destination,
+// the clock of steamcmd is paused while an item is installed
+func (s *SteamCmd) scan(scanner *bufio.Scanner, steamClock *clock) {
+	appDestination := s.cfg.Apps.Destinations()
+
+	// workshop id of the item steamcmd is currently downloading
+	var current string
+	// user of a login without result yet
+	var login string
+
+	for scanner.Scan() {
+		text := scanner.Text()
+		logger.Debug(text)
+
+		if m := loginRegex.FindStringSubmatch(text); m != nil {
+			login = m[1]
+			if m[2] != "" {
+				s.login(login, m[2])
+				login = ""
+			}
+			continue
+		}
+
+		if m := loginFailedRegex.FindStringSubmatch(text); m != nil && login != "" {
+			s.login(login, m[0])
+			login = ""
+			continue
+		}
+
+		if m := downloadingRegex.FindStringSubmatch(text); m != nil {
+			current = m[1]
+			s.emit(s.item(event.ItemStarted, current))
+			continue
+		}
+
+		if m := progressRegex.FindStringSubmatch(text); m != nil && current != "" {
+			e := s.item(event.ItemProgress, current)
+			e.Done, _ = strconv.ParseInt(m[1], 10, 64)
+			e.Total, _ = strconv.ParseInt(m[2], 10, 64)
+			s.emit(e)
+			continue
+		}
+
+		if m := failedRegex.FindStringSubmatch(text); m != nil {
+			e := s.item(event.ItemFailed, m[1])
+			e.Error = m[2]
+			s.emit(e)
+			current = ""
+			continue
+		}
+
+		if downloadFolder := extractPathRegex.FindStringSubmatch(text); downloadFolder != nil {
+			current = ""
+			workshopID := filepath.Base(downloadFolder[1])
+
+			downloaded := s.item(event.ItemDownloaded, workshopID)
+			downloaded.Path = downloadFolder[1]
+			if m := downloadedBytesRegex.FindStringSubmatch(text); m != nil {
+				downloaded.Total, _ = strconv.ParseInt(m[1], 10, 64)
+				downloaded.Done = downloaded.Total
+			}
+			s.emit(downloaded)
+
+			// extract app id from path
+			if appID := appIDRegex.FindStringSubmatch(downloadFolder[1]); appID != nil {
+				steamClock.pause()
+				s.downloaded(appID[1], downloadFolder[1], appDestination[appID[1]])
+				steamClock.resume()
+			}
+		}
+	}
+}
+
+// downloaded installs a downloaded item or keeps it for later if the app is deferred
+func (s *SteamCmd) downloaded(appID, downloadFolder, destination string) {
+	if _, ok := s.deferred[appID]; ok {
+		s.pending[appID] = append(s.pending[appID], downloadFolder)
+		return
+	}
+	s.install(appID, downloadFolder, destination)
+}
+
+// install copies a downloaded item to the destination of its app
+func (s *SteamCmd) install(appID, downloadFolder, destination string) bool {
+	workshopID := filepath.Base(downloadFolder)
+	f := filepath.Join(downloadFolder, "mods")
+	var opts path.Options
+	if app := s.cfg.Apps.Get(appID); app != nil {
+		opts = app.InstallOptions()
+	}
+	opts.Progress = func(done, total int64) {
+		e := s.item(event.ItemCopying, workshopID)
+		e.Path = destination
+		e.Done = done
+		e.Total = total
+		s.emit(e)
+	}
+
+	if err := s.prepare(appID); err != nil {
+		logger.WithError(err).WithField("app_id", appID).WithField("workshop_id", workshopID).Error("not installing mod")
+		s.fail(workshopID, destination, err)
+		return false
+	}
+
+	if err := path.Install(f, destination, opts); err != nil {
+		logger.WithError(err).
+			WithField("app_id", appID).
+			WithField("workshop_id", workshopID).
+			WithField("source", f).
+			WithField("destination", destination).
+			Error("failed to copy mod")
+		s.fail(workshopID, destination, err)
+		return false
+	}
+
+	// steamcmd validates the download itself, mirrors are compared after copying
+	if s.validate && s.cfg.Steam.Offline() {
+		if r := verify.Dir(f, destination); r.Error != "" || len(r.Missing) > 0 || len(r.Modified) > 0 {
+			err := fmt.Errorf("installed files differ from the mirror: %s", r.Status())
+			if r.Error != "" {
+				err = fmt.Errorf("failed to verify installed files: %s", r.Error)
+			}
+			logger.WithError(err).WithField("workshop_id", workshopID).Error("failed to validate mod")
+			s.fail(workshopID, destination, err)
+			return false
+		}
+	}
+
+	copied := s.item(event.ItemCopied, workshopID)
+	copied.Path = destination
+	s.emit(copied)
+	return true
+}
+
+// BeforeInstall calls fn once per run before the first downloaded item of the app is installed.
+// If fn returns an error, no items of the app are installed and they fail with this error.
+func (s *SteamCmd) BeforeInstall(appID string, fn func() error) {
+	s.before[appID] = fn
+}
+
+// prepare calls the before function of the app on the first call of a run and returns its result
+func (s *SteamCmd) prepare(appID string) error {
+	fn, ok := s.before[appID]
+	if !ok {
+		return nil
+	}
+	if err, done := s.prepared[appID]; done {
 		return err
 	}
+	err := fn()
+	s.prepared[appID] = err
+	return err
+}
+
+// fail emits the failure of an item
+func (s *SteamCmd) fail(workshopID, destination string, err error) {
+	failed := s.item(event.ItemFailed, workshopID)
+	failed.Path = destination
+	failed.Error = err.Error()
+	s.emit(failed)
+}
+
+// DeferInstall installs the downloaded items of the app after steamcmd exited instead of right away.
+// around is called with a function installing all downloaded items of the app, but only if there are any.
+// If around returns an error without installing, the items fail with this error.
+func (s *SteamCmd) DeferInstall(appID string, around func(install func() error) error) {
+	s.deferred[appID] = around
+}
+
+// installDeferred installs the downloaded items of all deferred apps
+func (s *SteamCmd) installDeferred() {
+	for _, app := range s.cfg.Apps {
+		around, ok := s.deferred[app.AppID]
+		if !ok || len(s.pending[app.AppID]) == 0 {
+			continue
+		}
+
+		installed := false
+		err := around(func() error {
+			installed = true
+			failed := 0
+			for _, folder := range s.pending[app.AppID] {
+				if !s.install(app.AppID, folder, app.Path) {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to copy %d mods", failed)
+			}
+			return nil
+		})
+		if err != nil && !installed {
+			for _, folder := range s.pending[app.AppID] {
+				s.fail(filepath.Base(folder), app.Path, err)
+			}
+		} else if err != nil {
+			logger.WithError(err).WithField("app_id", app.AppID).Error("failed to install mods")
+		}
+	}
 }
@@ -13,12 +13,20 @@ type translation struct {
 	key      string // the key to use for the translation
 	text     string // the text to use for the translation
 	override bool   // override the default translation
+	param    bool   // use the parameter of the tag instead of the value of the field
 }
 
 // translations is a list of translations to register
 var translations = []translation{
 	{tag: "dir", key: "dir", text: "{0} is not a valid directory: {1}", override: true},
 	{tag: "file", key: "file", text: "{0} is not a valid file: {1}", override: true},
+	{tag: "required_without", key: "required_without", text: "{0} is required if {1} is not set", param: true},
+	{tag: "steamid", key: "steamid", text: "{0} must be a numeric Steam id: {1}"},
+	{tag: "writable", key: "writable", text: "{0} is not a writable directory: {1}"},
+	{tag: "duplicate", key: "duplicate", text: "{0} is a duplicate of {1}", param: true},
+	{tag: "overlap", key: "overlap", text: "{0} overlaps {1}, apps need separate paths", param: true},
+	{tag: "workshop", key: "workshop", text: "{0} does not exist in the workshop: {1}"},
+	{tag: "workshop_app", key: "workshop_app", text: "{0} is a mod of app {1}", param: true},
 }
 
 // RegisterDefaultTranslations registers the default translations and custom translations
@@ -31,6 +39,7 @@ func RegisterDefaultTranslations(v *validator.Validate, trans ut.Translator) (er
 
 	// register custom translations
 	for _, t := range translations {
+		t := t
 		err = v.RegisterTranslation(t.tag, trans, func(ut ut.Translator) error {
 			return ut.Add(t.key, t.text, t.override)
 		}, func(ut ut.Translator, fe validator.FieldError) string {
@@ -42,6 +51,10 @@ func RegisterDefaultTranslations(v *validator.Validate, trans ut.Translator) (er
 				value = fmt.Sprintf("%v", fe.Value())
 			}
 
+			if t.param {
+				value = fe.Param()
+			}
+
 			// translate the error
 			t, _ := ut.T(t.key, fe.Field(), value)
 			return t
@@ -0,0 +1,359 @@
+// Package vdf reads and writes the text KeyValues format of Valve, e.g. the .acf and .vdf files of steamcmd.
+//
+//	"AppWorkshop"
+//	{
+//		"appid"		"108600"
+//	}
+//
+// Keys are case-insensitive, the order of keys and duplicate keys are preserved.
+package vdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// KeyValue is a key with a string value or a block of children
+type KeyValue struct {
+	Key      string      // key of the value
+	Value    string      // value, empty for blocks
+	Children []*KeyValue // children of a block, nil for values
+}
+
+// NewValue returns a key with a value
+func NewValue(key, value string) *KeyValue {
+	return &KeyValue{Key: key, Value: value}
+}
+
+// NewBlock returns a key with a block of children
+func NewBlock(key string, children ...*KeyValue) *KeyValue {
+	if children == nil {
+		children = []*KeyValue{}
+	}
+	return &KeyValue{Key: key, Children: children}
+}
+
+// IsBlock returns true if kv is a block
+func (kv *KeyValue) IsBlock() bool {
+	return kv != nil && kv.Children != nil
+}
+
+// Get returns the first child with the key, nil if there is none or kv is nil
+func (kv *KeyValue) Get(key string) *KeyValue {
+	if kv == nil {
+		return nil
+	}
+	for _, child := range kv.Children {
+		if strings.EqualFold(child.Key, key) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Path returns the child at the path of keys, e.g. Path("AppWorkshop", "appid")
+func (kv *KeyValue) Path(keys ...string) *KeyValue {
+	for _, key := range keys {
+		kv = kv.Get(key)
+	}
+	return kv
+}
+
+// String returns the value of the child with the key, empty if there is none
+func (kv *KeyValue) String(key string) string {
+	if child := kv.Get(key); child != nil {
+		return child.Value
+	}
+	return ""
+}
+
+// Int returns the value of the child with the key as number, 0 if there is none or it is not a number
+func (kv *KeyValue) Int(key string) int64 {
+	n, _ := strconv.ParseInt(kv.String(key), 10, 64)
+	return n
+}
+
+// Set sets the value of the first child with the key or appends a new child
+func (kv *KeyValue) Set(key, value string) {
+	if child := kv.Get(key); child != nil {
+		child.Value = value
+		child.Children = nil
+		return
+	}
+	kv.Children = append(kv.Children, NewValue(key, value))
+}
+
+// Add appends a child
+func (kv *KeyValue) Add(child *KeyValue) {
+	if kv.Children == nil {
+		kv.Children = []*KeyValue{}
+	}
+	kv.Children = append(kv.Children, child)
+}
+
+// SyntaxErr is an error in the KeyValues text
+type SyntaxErr struct {
+	Line int    // line of the error starting at 1
+	Msg  string // description of the error
+}
+
+func (e *SyntaxErr) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Parse reads KeyValues text, the top level keys are the children of the returned block
+func Parse(r io.Reader) (*KeyValue, error) {
+	p := &parser{r: bufio.NewReader(r), line: 1}
+	root := NewBlock("")
+	if err := p.block(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Unmarshal parses b like Parse
+func Unmarshal(b []byte) (*KeyValue, error) {
+	return Parse(bytes.NewReader(b))
+}
+
+// token types of the parser
+const (
+	tokenEOF = iota
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenCondition
+)
+
+type parser struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxErr{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// block reads keys and their values or blocks into kv until the end of the block or the input
+func (p *parser) block(kv *KeyValue, nested bool) error {
+	for {
+		typ, key, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case tokenEOF:
+			if nested {
+				return p.errorf("unexpected end of input, missing }")
+			}
+			return nil
+		case tokenClose:
+			if !nested {
+				return p.errorf("unexpected }")
+			}
+			return nil
+		case tokenOpen:
+			return p.errorf("unexpected {, missing key")
+		case tokenCondition:
+			return p.errorf("unexpected condition %s", key)
+		}
+
+		typ, value, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case tokenString:
+			kv.Add(NewValue(key, value))
+		case tokenOpen:
+			child := NewBlock(key)
+			if err := p.block(child, true); err != nil {
+				return err
+			}
+			kv.Add(child)
+		default:
+			return p.errorf("missing value of %q", key)
+		}
+		if err := p.skipCondition(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipCondition skips a platform condition like [$WIN32] after a value
+func (p *parser) skipCondition() error {
+	if err := p.skipSpace(); err != nil {
+		return err
+	}
+	b, err := p.r.Peek(1)
+	if err != nil || b[0] != '[' {
+		return nil
+	}
+	_, _, err = p.next()
+	return err
+}
+
+// skipSpace skips whitespace, new lines and comments
+func (p *parser) skipSpace() error {
+	for {
+		b, err := p.r.Peek(2)
+		if len(b) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case b[0] == '\n':
+			p.line++
+		case b[0] == ' ' || b[0] == '\t' || b[0] == '\r':
+		case len(b) == 2 && b[0] == '/' && b[1] == '/':
+			if _, err := p.r.ReadString('\n'); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			p.line++
+			continue
+		default:
+			return nil
+		}
+		_, _ = p.r.ReadByte()
+	}
+}
+
+// next returns the next token
+func (p *parser) next() (int, string, error) {
+	if err := p.skipSpace(); err != nil {
+		return tokenEOF, "", err
+	}
+	b, err := p.r.ReadByte()
+	if err == io.EOF {
+		return tokenEOF, "", nil
+	}
+	if err != nil {
+		return tokenEOF, "", err
+	}
+
+	switch b {
+	case '{':
+		return tokenOpen, "", nil
+	case '}':
+		return tokenClose, "", nil
+	case '"':
+		s, err := p.quoted()
+		return tokenString, s, err
+	case '[':
+		s, err := p.r.ReadString(']')
+		if err != nil {
+			return tokenEOF, "", p.errorf("unterminated condition")
+		}
+		return tokenCondition, "[" + s, nil
+	default:
+		if err := p.r.UnreadByte(); err != nil {
+			return tokenEOF, "", err
+		}
+		s, err := p.unquoted()
+		return tokenString, s, err
+	}
+}
+
+// quoted reads a quoted string after the opening quote, escape sequences are replaced
+func (p *parser) quoted() (string, error) {
+	var s strings.Builder
+	for {
+		b, err := p.r.ReadByte()
+		if err == io.EOF {
+			return "", p.errorf("unterminated string")
+		}
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '"':
+			return s.String(), nil
+		case '\n':
+			p.line++
+			s.WriteByte(b)
+		case '\\':
+			e, err := p.r.ReadByte()
+			if err != nil {
+				return "", p.errorf("unterminated string")
+			}
+			switch e {
+			case 'n':
+				s.WriteByte('\n')
+			case 't':
+				s.WriteByte('\t')
+			case '\\', '"':
+				s.WriteByte(e)
+			default:
+				// paths on windows contain single backslashes
+				s.WriteByte('\\')
+				s.WriteByte(e)
+			}
+		default:
+			s.WriteByte(b)
+		}
+	}
+}
+
+// unquoted reads a string up to whitespace, a brace or a quote
+func (p *parser) unquoted() (string, error) {
+	var s strings.Builder
+	for {
+		b, err := p.r.ReadByte()
+		if err == io.EOF {
+			return s.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n', '{', '}', '"':
+			return s.String(), p.r.UnreadByte()
+		default:
+			s.WriteByte(b)
+		}
+	}
+}
+
+// Encode writes the children of root as KeyValues text indented with tabs like steamcmd
+func Encode(w io.Writer, root *KeyValue) error {
+	bw := bufio.NewWriter(w)
+	for _, kv := range root.Children {
+		encode(bw, kv, 0)
+	}
+	return bw.Flush()
+}
+
+// Marshal returns the children of root as KeyValues text
+func Marshal(root *KeyValue) []byte {
+	var b bytes.Buffer
+	_ = Encode(&b, root)
+	return b.Bytes()
+}
+
+func encode(w *bufio.Writer, kv *KeyValue, depth int) {
+	indent := strings.Repeat("\t", depth)
+	if kv.IsBlock() {
+		_, _ = fmt.Fprintf(w, "%s%s\n%s{\n", indent, quote(kv.Key), indent)
+		for _, child := range kv.Children {
+			encode(w, child, depth+1)
+		}
+		_, _ = fmt.Fprintf(w, "%s}\n", indent)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%s%s\t\t%s\n", indent, quote(kv.Key), quote(kv.Value))
+}
+
+// quote quotes s and escapes quotes and backslashes
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,202 @@
+package vdf
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want *KeyValue
+	}{
+		{
+			name: "values and blocks",
+			text: `"AppWorkshop"
+{
+	"appid"		"108600"
+	"WorkshopItemsInstalled"
+	{
+		"111"
+		{
+			"size"		"1024"
+		}
+	}
+}`,
+			want: NewBlock("",
+				NewBlock("AppWorkshop",
+					NewValue("appid", "108600"),
+					NewBlock("WorkshopItemsInstalled",
+						NewBlock("111", NewValue("size", "1024")),
+					),
+				),
+			),
+		},
+		{
+			name: "unquoted strings and comments",
+			text: "// comment\nkey value // trailing\nblock { child \"quoted value\" }\n",
+			want: NewBlock("",
+				NewValue("key", "value"),
+				NewBlock("block", NewValue("child", "quoted value")),
+			),
+		},
+		{
+			name: "empty block and duplicate keys",
+			text: `"a" {} "b" "1" "B" "2"`,
+			want: NewBlock("", NewBlock("a"), NewValue("b", "1"), NewValue("B", "2")),
+		},
+		{
+			name: "escape sequences",
+			text: `"key" "a\"b\\c\td\ne"`,
+			want: NewBlock("", NewValue("key", "a\"b\\c\td\ne")),
+		},
+		{
+			name: "windows paths",
+			text: `"escaped" "C:\\Program Files (x86)\\Steam" "single" "C:\Program Files (x86)\Steam\steamapps"`,
+			want: NewBlock("",
+				NewValue("escaped", `C:\Program Files (x86)\Steam`),
+				NewValue("single", `C:\Program Files (x86)\Steam\steamapps`),
+			),
+		},
+		{
+			name: "conditions",
+			text: `"path" "C:\\Steam" [$WIN32]
+"path" "/opt/steam" [!$WIN32]
+"block" { "key" "value" [$OSX] } [$LINUX]
+"next" "value"`,
+			want: NewBlock("",
+				NewValue("path", `C:\Steam`),
+				NewValue("path", "/opt/steam"),
+				NewBlock("block", NewValue("key", "value")),
+				NewValue("next", "value"),
+			),
+		},
+		{
+			name: "empty input",
+			text: "",
+			want: NewBlock(""),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %s, want %s", Marshal(got), Marshal(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseSyntaxErr(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		line int
+		msg  string
+	}{
+		{name: "missing close", text: "\"a\"\n{\n\t\"b\" \"c\"\n", line: 4, msg: "missing }"},
+		{name: "unexpected close", text: "\"a\" \"b\"\n}", line: 2, msg: "unexpected }"},
+		{name: "missing key", text: "\"a\" {\n}\n{", line: 3, msg: "missing key"},
+		{name: "missing value", text: "\"a\" {\n\t\"b\"\n}", line: 3, msg: `missing value of "b"`},
+		{name: "unterminated string", text: "\"a\"\n\"b", line: 2, msg: "unterminated string"},
+		{name: "unterminated condition", text: "\"a\" \"b\" [$WIN32", line: 1, msg: "unterminated condition"},
+		{name: "condition without value", text: "\n[$WIN32] \"a\" \"b\"", line: 2, msg: "unexpected condition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.text))
+			var syntaxErr *SyntaxErr
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse() error = %v, want SyntaxErr", err)
+			}
+			if syntaxErr.Line != tt.line || !strings.Contains(syntaxErr.Msg, tt.msg) {
+				t.Errorf("Parse() error = %v, want line %d: %s", err, tt.line, tt.msg)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	root := NewBlock("",
+		NewBlock("AppWorkshop",
+			NewValue("appid", "108600"),
+			NewBlock("WorkshopItemsInstalled"),
+		),
+	)
+	want := "\"AppWorkshop\"\n{\n\t\"appid\"\t\t\"108600\"\n\t\"WorkshopItemsInstalled\"\n\t{\n\t}\n}\n"
+	if got := string(Marshal(root)); got != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		root *KeyValue
+	}{
+		{
+			name: "nested blocks",
+			root: NewBlock("",
+				NewBlock("AppWorkshop",
+					NewValue("appid", "108600"),
+					NewBlock("WorkshopItemsInstalled",
+						NewBlock("111", NewValue("size", "1024"), NewValue("timeupdated", "1700000000")),
+						NewBlock("222"),
+					),
+				),
+			),
+		},
+		{
+			name: "special characters",
+			root: NewBlock("",
+				NewValue("path", `C:\Program Files (x86)\Steam\new`),
+				NewValue(`quoted "key"`, "line\nbreak\tand tab"),
+				NewValue("empty", ""),
+				NewValue("unicode", "Мод 模组"),
+			),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unmarshal(Marshal(tt.root))
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.root) {
+				t.Errorf("round trip = %s, want %s", Marshal(got), Marshal(tt.root))
+			}
+		})
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	root, err := Unmarshal([]byte(`"AppWorkshop" { "appid" "108600" "size" "x" }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workshop := root.Get("appworkshop")
+	if got := workshop.String("APPID"); got != "108600" {
+		t.Errorf("String() = %q, want 108600", got)
+	}
+	if got := root.Path("AppWorkshop", "appid").Value; got != "108600" {
+		t.Errorf("Path() = %q, want 108600", got)
+	}
+	if got := workshop.Int("size"); got != 0 {
+		t.Errorf("Int() of an invalid number = %d, want 0", got)
+	}
+	if got := root.Path("missing", "appid"); got != nil {
+		t.Errorf("Path() of a missing key = %v, want nil", got)
+	}
+
+	workshop.Set("appid", "4000")
+	workshop.Set("new", "1")
+	if got := workshop.Int("appid") + workshop.Int("new"); got != 4001 {
+		t.Errorf("values after Set() sum to %d, want 4001", got)
+	}
+}
@@ -0,0 +1,210 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/Cehir/steam-workshop-downloader/pkg/config"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	NotCachedErr = errors.New("mod is not in the workshop cache")
+)
+
+// Result is the result of the verification of a mod
+type Result struct {
+	AppID      string   `json:"app_id" yaml:"app_id"`                                 // steam app id
+	WorkshopID string   `json:"workshop_id" yaml:"workshop_id"`                       // workshop id of the mod
+	Name       string   `json:"name,omitempty" yaml:"name,omitempty"`                 // configured name of the mod
+	Source     string   `json:"source" yaml:"source"`                                 // mods folder in the workshop cache
+	Path       string   `json:"path" yaml:"path"`                                     // destination of the app
+	Files      int      `json:"files" yaml:"files"`                                   // number of files in the workshop cache
+	Missing    []string `json:"missing,omitempty" yaml:"missing,omitempty"`           // files missing in the destination
+	Modified   []string `json:"modified,omitempty" yaml:"modified,omitempty"`         // files with a different size or content
+	Extra      []string `json:"extra,omitempty" yaml:"extra,omitempty"`               // files in the folders of the mod that are not in the workshop cache
+	Error      string   `json:"error,omitempty" yaml:"error,omitempty"`               // error that prevented the verification
+	Repaired   bool     `json:"repaired,omitempty" yaml:"repaired,omitempty"`         // the mod was repaired
+	RepairErr  string   `json:"repair_error,omitempty" yaml:"repair_error,omitempty"` // error of the repair
+}
+
+// OK returns true if the destination matches the workshop cache
+func (r *Result) OK() bool {
+	return r.Error == "" && len(r.Missing) == 0 && len(r.Modified) == 0 && len(r.Extra) == 0
+}
+
+// Status returns a short description of the result
+func (r *Result) Status() string {
+	switch {
+	case r.Error != "":
+		return "error"
+	case r.OK():
+		return "ok"
+	default:
+		return fmt.Sprintf("%d missing, %d modified, %d extra", len(r.Missing), len(r.Modified), len(r.Extra))
+	}
+}
+
+// Apps verifies all mods of the apps against the workshop content directory of steam
+func Apps(steam *config.Steam, apps config.Apps) []*Result {
+	var results []*Result
+	for _, app := range apps {
+		for _, mod := range app.Mods {
+			source := filepath.Join(steam.ModDir(app.AppID, mod.WorkshopID), "mods")
+			r := Dir(source, app.Path)
+			r.AppID = app.AppID
+			r.WorkshopID = mod.WorkshopID
+			r.Name = mod.Name
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// Dir compares the files of source with the files in dst.
+// Files in dst are extra if they are in a top level folder of source but not in source.
+func Dir(source, dst string) *Result {
+	r := &Result{Source: source, Path: dst}
+	if _, err := os.Stat(source); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			r.Error = NotCachedErr.Error()
+		} else {
+			r.Error = err.Error()
+		}
+		return r
+	}
+
+	files := map[string]bool{}
+	err := filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = true
+		r.Files++
+
+		equal, err := equalFiles(path, filepath.Join(dst, rel))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			r.Missing = append(r.Missing, rel)
+		case err != nil:
+			return err
+		case !equal:
+			r.Modified = append(r.Modified, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+
+	extra, err := extraFiles(source, dst, files)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Extra = extra
+	return r
+}
+
+// extraFiles returns the files in the top level folders of source in dst that are not in files
+func extraFiles(source, dst string, files map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var extra []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		// the folder may be a link to the workshop cache
+		root, err := filepath.EvalSymlinks(filepath.Join(dst, entry.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.Join(entry.Name(), rel)
+			if !files[rel] {
+				extra = append(extra, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(extra)
+	return extra, nil
+}
+
+// equalFiles compares the size and SHA-256 of two files
+func equalFiles(a, b string) (bool, error) {
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+
+	hashA, err := Hash(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := Hash(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hashA, hashB), nil
+}
+
+// Hash returns the SHA-256 of a file
+func Hash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
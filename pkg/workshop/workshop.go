@@ -0,0 +1,134 @@
+package workshop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultURL is the Steam Web API endpoint for workshop item details, it does not require an API key
+const DefaultURL = "https://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1/"
+
+// batchSize is the maximum number of items requested at once
+const batchSize = 100
+
+// ResultOK is the steam result code of an existing item
+const ResultOK = 1
+
+// Client fetches workshop item details from the Steam Web API
+type Client struct {
+	URL  string       // endpoint of GetPublishedFileDetails
+	HTTP *http.Client // client used for requests
+}
+
+func NewClient() *Client {
+	return &Client{
+		URL:  DefaultURL,
+		HTTP: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Details are the details of a workshop item
+type Details struct {
+	WorkshopID  string `json:"publishedfileid"` // Steam Workshop ID
+	Result      int    `json:"result"`          // steam result code, 1 if the item exists
+	AppID       Number `json:"consumer_app_id"` // Steam App ID the item belongs to
+	Title       string `json:"title"`           // title of the item
+	FileSize    Number `json:"file_size"`       // size of the item in bytes
+	TimeCreated Number `json:"time_created"`    // unix time the item was created
+	TimeUpdated Number `json:"time_updated"`    // unix time the item was last updated
+}
+
+// Exists returns true if the item exists in the workshop
+func (d *Details) Exists() bool {
+	return d != nil && d.Result == ResultOK
+}
+
+// Updated returns the time the item was last updated
+func (d *Details) Updated() time.Time {
+	if d == nil || d.TimeUpdated == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(d.TimeUpdated), 0)
+}
+
+type response struct {
+	Response struct {
+		Result  int        `json:"result"`
+		Details []*Details `json:"publishedfiledetails"`
+	} `json:"response"`
+}
+
+// Details returns the details of the given workshop ids by workshop id
+func (c *Client) Details(ctx context.Context, workshopIDs []string) (map[string]*Details, error) {
+	details := make(map[string]*Details, len(workshopIDs))
+	for start := 0; start < len(workshopIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(workshopIDs) {
+			end = len(workshopIDs)
+		}
+		batch, err := c.fetch(ctx, workshopIDs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range batch {
+			details[d.WorkshopID] = d
+		}
+	}
+	return details, nil
+}
+
+// fetch requests the details of the given workshop ids
+func (c *Client) fetch(ctx context.Context, workshopIDs []string) ([]*Details, error) {
+	form := url.Values{}
+	form.Set("itemcount", strconv.Itoa(len(workshopIDs)))
+	for i, id := range workshopIDs {
+		form.Set(fmt.Sprintf("publishedfileids[%d]", i), id)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request workshop details: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to request workshop details: %s", resp.Status)
+	}
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode workshop details: %w", err)
+	}
+	return r.Response.Details, nil
+}
+
+// Number is a JSON number that the Steam Web API sometimes encodes as string
+type Number int64
+
+func (n *Number) UnmarshalJSON(b []byte) error {
+	s := string(bytes.Trim(b, `"`))
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s: %w", b, err)
+	}
+	*n = Number(v)
+	return nil
+}